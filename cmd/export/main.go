@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
+)
+
+// Exporta os pagamentos de uma janela direto do Redis, sem passar pela API:
+//
+//	go run ./cmd/export -from 2025-07-10T12:00:00Z -to 2025-07-10T13:00:00Z -format ndjson -o payments.ndjson
+func main() {
+	from := flag.String("from", "", "início da janela (RFC3339)")
+	to := flag.String("to", "", "fim da janela (RFC3339)")
+	format := flag.String("format", "csv", "csv ou ndjson")
+	output := flag.String("o", "", "arquivo de saída (padrão: stdout)")
+	tenantID := flag.String("tenant", "", "tenant a exportar (padrão: tenant sem prefixo)")
+	flag.Parse()
+
+	if err := run(*from, *to, *format, *output, *tenantID); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(from, to, format, output, tenantID string) error {
+	logger.InitLogger(os.Stderr)
+
+	f, err := payment.ParseExportFormat(format)
+	if err != nil {
+		return err
+	}
+
+	params := payment.PaymentSummaryParams{Filter: from != "" || to != ""}
+	if params.Filter {
+		if params.From, err = time.Parse(time.RFC3339Nano, from); err != nil {
+			return err
+		}
+		if params.To, err = time.Parse(time.RFC3339Nano, to); err != nil {
+			return err
+		}
+	}
+
+	out := os.Stdout
+	if output != "" {
+		if out, err = os.Create(output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	w := bufio.NewWriter(out)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx = tenant.WithTenant(ctx, tenantID)
+
+	service := payment.NewService(payment.NewRepository(database.GetRedis()))
+	if err := service.ExportPayments(ctx, params, f, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
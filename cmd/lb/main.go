@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/balancer"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
+)
+
+// Load balancer na frente das instâncias da API, no lugar do nginx:
+//
+//	LB_PORT=9999 LB_UPSTREAMS=unix:/sockets/api1.sock,unix:/sockets/api2.sock go run ./cmd/lb
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	logger.InitLogger(os.Stdout)
+
+	cfg, err := config.LoadBalancer()
+	if err != nil {
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			for _, e := range verr.Errors {
+				slog.Error("Invalid configuration", "error", e)
+			}
+		}
+		return err
+	}
+	if err := logger.Configure(cfg.Log); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	lb := balancer.New(*cfg)
+	defer lb.Close()
+	go lb.Run(ctx)
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           lb,
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("Starting load balancer", "port", cfg.Port, "upstreams", len(cfg.Upstreams))
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		slog.Info("Shutting down load balancer...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
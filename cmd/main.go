@@ -5,16 +5,22 @@ import (
 	"errors"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"syscall"
 	"time"
 
 	"github.com/oprimogus/rinha-backend-2025/internal/api"
 	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/apikey"
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
 	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/webhook"
 	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/listener"
 	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
 )
 
@@ -25,51 +31,105 @@ func main() {
 }
 
 func run() error {
-	// Config
-	cfg := config.GetInstance()
-	
 	// Config logger
 	logger.InitLogger(os.Stdout)
 	slog.Info("Starting application...")
 	
+	// Config: para no start com todos os valores inválidos listados
+	cfg, err := config.Load()
+	if err != nil {
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			for _, e := range verr.Errors {
+				slog.Error("Invalid configuration", "error", e)
+			}
+		}
+		return err
+	}
+	if err := logger.Configure(cfg.Log); err != nil {
+		return err
+	}
+	// O reload roda para qualquer tunable; o log só é reaplicado quando a
+	// seção dele mudou, senão os níveis de PUT /admin/log-levels se perdem.
+	appliedLog := cfg.Log
+	config.OnReload(func(c *config.Config) {
+		if reflect.DeepEqual(c.Log, appliedLog) {
+			return
+		}
+		appliedLog = c.Log
+		if err := logger.Configure(c.Log); err != nil {
+			slog.Error("Failed to apply log configuration", "error", err)
+		}
+	})
+	
 	// Database
 	db := database.GetRedis()
 	
-	workerCount := 20
-	slog.Info("Worker configuration", "count", workerCount)
+	workerCount := cfg.Payment.Workers
+	slog.Info("Worker configuration", "count", workerCount, "queue_size", cfg.Payment.QueueSize)
 	
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+
+	// Chave admin inicial, para criar as demais por /admin/api-keys
+	if err := apikey.NewService(apikey.NewRepository(db), externalservices.NewRegistry(cfg).Known).Bootstrap(ctx, cfg.Auth.BootstrapKey); err != nil {
+		slog.Error("Failed to bootstrap API key", "error", err)
+		return err
+	}
 	
 	// Workers
 	repo := payment.NewRepository(db)
-	paymentWorker := payment.NewPaymentWorker(repo, workerCount)
+	paymentWorker := payment.NewPaymentWorker(repo, cfg.Payment)
+	config.OnReload(func(c *config.Config) {
+		paymentWorker.SetMaxConcurrency(c.Payment.MaxConcurrency)
+	})
 	
 	// Inicia o worker em background
 	go func() {
 		slog.Info("Starting payment worker...")
 		paymentWorker.Run(ctx, workerCount)
 	}()
+
+	// Webhooks de conclusão de pagamento
+	webhookWorker := webhook.NewDeliveryWorker(db)
+	if cfg.Webhook.Enabled {
+		webhookWorker.Run(ctx)
+	}
 	
+	// Hot reload do arquivo de configuração
+	go config.Watch(ctx)
+	
+	// Eventos de mudança de status (SSE)
+	events := payment.NewEventHub(db)
+	go events.Run(ctx)
+
 	// Inicializa o servidor HTTP
-	handler := api.InitRouter(db)
+	handler := api.InitRouter(db, events, paymentWorker)
 	srv := &http.Server{
-		Addr:         ":" + cfg.API.Port,
 		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.API.ReadTimeout,
+		WriteTimeout: cfg.API.WriteTimeout,
+		IdleTimeout:  cfg.API.IdleTimeout,
 	}
+	// Shutdown não cancela requisições em andamento; fecha os streams SSE.
+	srv.RegisterOnShutdown(events.Close)
 	
-	// Inicia o servidor em background
-	go func() {
-		slog.Info("Starting HTTP server", "port", cfg.API.Port)
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			slog.Error("HTTP server failed", "error", err)
-			cancel() // Cancela o contexto se o servidor falhar
-		}
-	}()
+	// Inicia o servidor em background, na porta TCP e/ou no Unix socket
+	listeners, err := listen(cfg.API)
+	if err != nil {
+		slog.Error("Failed to listen", "error", err)
+		return err
+	}
+	for _, l := range listeners {
+		go func() {
+			slog.Info("Starting HTTP server", "network", l.Addr().Network(), "address", l.Addr().String())
+			if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("HTTP server failed", "network", l.Addr().Network(), "error", err)
+				cancel() // Cancela o contexto se o servidor falhar
+			}
+		}()
+	}
 	
 	// Canal para capturar sinais de shutdown
 	quit := make(chan os.Signal, 1)
@@ -84,14 +144,37 @@ func run() error {
 	}
 	
 	// Graceful shutdown
-	return gracefulShutdown(srv, paymentWorker, cancel)
+	return gracefulShutdown(srv, paymentWorker, webhookWorker, cancel, cfg.API.ShutdownTimeout)
+}
+
+// listen abre os listeners configurados. Shutdown fecha todos e remove o
+// Unix socket.
+func listen(cfg config.API) ([]net.Listener, error) {
+	var listeners []net.Listener
+	if cfg.Port != "" {
+		l, err := net.Listen("tcp", ":"+cfg.Port)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	if cfg.Socket != "" {
+		l, err := listener.Unix(cfg.Socket, cfg.SocketMode)
+		if err != nil {
+			for _, open := range listeners {
+				open.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
 }
 
-func gracefulShutdown(srv *http.Server, paymentWorker *payment.PaymentWorker, cancel context.CancelFunc) error {
+func gracefulShutdown(srv *http.Server, paymentWorker *payment.PaymentWorker, webhookWorker *webhook.DeliveryWorker, cancel context.CancelFunc, shutdownTimeout time.Duration) error {
 	slog.Info("Starting graceful shutdown...")
 	
 	// Timeout total para shutdown
-	shutdownTimeout := 30 * time.Second
 	ctx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer shutdownCancel()
 	
@@ -121,6 +204,13 @@ func gracefulShutdown(srv *http.Server, paymentWorker *payment.PaymentWorker, ca
 		
 		// 3. Cancela o contexto principal
 		cancel()
+
+		// 4. Aguarda o worker de webhooks terminar a tentativa em andamento
+		if err := webhookWorker.Shutdown(ctx); err != nil {
+			slog.Error("Webhook worker shutdown failed", "error", err)
+			done <- err
+			return
+		}
 		
 		slog.Info("Graceful shutdown completed successfully")
 		done <- nil
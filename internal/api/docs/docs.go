@@ -0,0 +1,49 @@
+package docs
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OpenAPI é a especificação da API. É escrita à mão: ao mudar uma rota,
+// atualize o openapi.json; o TestOpenAPIMatchesRoutes falha se eles divergirem.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// A UI é o Swagger UI servido pelo CDN, apontando para /docs/openapi.json.
+const page = `<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Payments API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "docs/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+func getSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(OpenAPI)
+}
+
+func getUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(page))
+}
+
+func SetupRoutes(r *chi.Mux) {
+	r.Get("/docs", getUI)
+	r.Get("/docs/openapi.json", getSpec)
+}
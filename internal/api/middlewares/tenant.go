@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+// Tenant resolve o tenant pelo header X-Tenant-Id e o coloca no contexto.
+// Sem header a requisição segue no tenant padrão; tenants não cadastrados são
+// recusados para que nada seja gravado fora de um namespace conhecido.
+func Tenant(known func(id string) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(tenant.Header)
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := tenant.Validate(id); err != nil {
+				xerror.Write(w, r, xerror.NewValidationError(tenant.Header, err.Error(), err))
+				return
+			}
+			if !known(id) {
+				xerror.Write(w, r, tenant.ErrUnknownTenant)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), id)))
+		})
+	}
+}
@@ -7,21 +7,45 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/oprimogus/rinha-backend-2025/internal/api/docs"
 	"github.com/oprimogus/rinha-backend-2025/internal/api/middlewares"
 	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/admin"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/apikey"
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/health"
 	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/ratelimit"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/webhook"
 	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
 	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
 )
 
-func InitRouter(db *database.Redis) http.Handler {
+func InitRouter(db *database.Redis, events *payment.EventHub, worker *payment.PaymentWorker) http.Handler {
 	cfg := config.GetInstance()
 	r := chi.NewRouter()
 	r.Use(logger.LoggingMiddleware)
 	r.Use(middlewares.JSON)
 	r.Use(middleware.Recoverer)
+	registry := externalservices.NewRegistry(cfg)
+	r.Use(apikey.Middleware(apikey.NewService(apikey.NewRepository(db), registry.Known), cfg.Auth.Enabled))
+	r.Use(ratelimit.Middleware(ratelimit.NewLimiter(db), func() config.RateLimit { return config.GetInstance().RateLimit }, r))
+	r.Use(middlewares.Tenant(registry.Known))
 	
-	payment.SetupRoutes(r, db)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusNotFound, "route not found", nil))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusMethodNotAllowed, "method not allowed", nil))
+	})
+
+	payment.SetupRoutes(r, db, events)
+	webhook.SetupRoutes(r, db)
+	apikey.SetupRoutes(r, db, registry.Known)
+	admin.SetupRoutes(r)
+	health.SetupRoutes(r, db, worker)
+	docs.SetupRoutes(r)
 
 	slog.Info(fmt.Sprintf("Docs available in http://localhost:%s%s/docs", cfg.API.Port, cfg.API.BasePath))
 	slog.Info(fmt.Sprintf("Listening and serving in 0.0.0.0:%v", cfg.API.Port))
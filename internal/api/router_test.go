@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/api/docs"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPIMatchesRoutes falha quando uma rota é criada, removida ou
+// renomeada sem atualizar o internal/api/docs/openapi.json, e vice-versa.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(docs.OpenAPI, &spec))
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+
+	var documented []string
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	var registered []string
+	router := InitRouter(&database.Redis{}, nil, nil).(chi.Routes)
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered = append(registered, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	slices.Sort(documented)
+	slices.Sort(registered)
+	assert.Equal(t, registered, documented)
+}
+
+// TestOpenAPIRefs garante que todo $ref aponta para um componente existente.
+func TestOpenAPIRefs(t *testing.T) {
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(docs.OpenAPI, &spec))
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				var node any = spec
+				for part := range strings.SplitSeq(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := node.(map[string]any)
+					node = m[part]
+				}
+				assert.NotNil(t, node, ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/subosito/gotenv"
+)
+
+// Balancer é a configuração do cmd/lb, o load balancer que fica na frente
+// das instâncias da API. Ela é lida à parte da Config da API: o lb não
+// precisa de Redis nem dos processadores.
+type Balancer struct {
+	Log Log
+	// Port é a porta TCP onde o lb recebe as requisições.
+	Port string
+	// Upstreams são as instâncias da API: "unix:/sockets/api1.sock" ou
+	// "api2:8080". LB_UPSTREAMS="unix:/sockets/api1.sock,api2:8080".
+	Upstreams []Upstream
+	// HealthPath é consultado a cada HealthInterval em cada upstream; depois de
+	// FailThreshold respostas fora de 2xx seguidas o upstream sai do
+	// balanceamento, e volta na primeira resposta 2xx. Se todos saírem, o lb
+	// distribui entre todos em vez de recusar as requisições.
+	HealthPath     string
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+	FailThreshold  int
+	// MaxIdleConns é quantas conexões ociosas são mantidas por upstream.
+	MaxIdleConns    int
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+type Upstream struct {
+	// Network é "tcp" ou "unix".
+	Network string
+	Address string
+}
+
+func (u Upstream) String() string {
+	return u.Network + ":" + u.Address
+}
+
+// LoadBalancer lê a configuração do lb, com os mesmos padrões de validação
+// do Load: todos os valores inválidos vêm no *ValidationError.
+func LoadBalancer() (*Balancer, error) {
+	if err := gotenv.Load(); err != nil {
+		slog.Info("arquivo .env não encontrado, usando variáveis de ambiente")
+	}
+	l := &loader{}
+	c := &Balancer{
+		Log:             l.log(),
+		Port:            l.envPort("LB_PORT", "9999"),
+		Upstreams:       l.upstreams(),
+		HealthPath:      l.envString("LB_HEALTH_PATH", "/readyz"),
+		HealthInterval:  l.envPositiveDuration("LB_HEALTH_INTERVAL", 2*time.Second),
+		HealthTimeout:   l.envPositiveDuration("LB_HEALTH_TIMEOUT", time.Second),
+		FailThreshold:   l.envPositiveInt("LB_FAIL_THRESHOLD", 2),
+		MaxIdleConns:    l.envPositiveInt("LB_MAX_IDLE_CONNS", 256),
+		IdleTimeout:     l.envPositiveDuration("LB_IDLE_TIMEOUT", 90*time.Second),
+		ShutdownTimeout: l.envPositiveDuration("LB_SHUTDOWN_TIMEOUT", 10*time.Second),
+	}
+	if !strings.HasPrefix(c.HealthPath, "/") {
+		l.fail("LB_HEALTH_PATH", fmt.Errorf("caminho %q precisa começar com /", c.HealthPath))
+	}
+	if len(l.errs) > 0 {
+		return c, &ValidationError{Errors: l.errs}
+	}
+	return c, nil
+}
+
+func (l *loader) upstreams() []Upstream {
+	var upstreams []Upstream
+	for addr := range strings.SplitSeq(l.envString("LB_UPSTREAMS", ""), ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+			if path == "" {
+				l.fail("LB_UPSTREAMS", fmt.Errorf("upstream %q sem caminho do socket", addr))
+				continue
+			}
+			upstreams = append(upstreams, Upstream{Network: "unix", Address: path})
+			continue
+		}
+		if !strings.Contains(addr, ":") {
+			l.fail("LB_UPSTREAMS", fmt.Errorf("upstream %q inválido, use host:porta ou unix:/caminho", addr))
+			continue
+		}
+		upstreams = append(upstreams, Upstream{Network: "tcp", Address: addr})
+	}
+	if strings.Trim(l.envString("LB_UPSTREAMS", ""), ", ") == "" {
+		l.fail("LB_UPSTREAMS", errors.New("nenhum upstream configurado"))
+	}
+	return upstreams
+}
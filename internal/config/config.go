@@ -1,24 +1,85 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/subosito/gotenv"
 )
 
-var cfg *Config
+var (
+	cfg    atomic.Pointer[Config]
+	cfgErr error
+)
 
 type Config struct {
+    File File
+    Log Log
     API API
     Redis Redis
     ExternalServices ExternalServices
+    Payment Payment
+    Webhook Webhook
+    Auth Auth
+    RateLimit RateLimit
+    // Tenants lista as unidades de negócio além do tenant padrão (sem header).
+    Tenants []Tenant
+}
+
+// File é o arquivo de configuração opcional (CONFIG_FILE), em YAML ou TOML.
+// As variáveis de ambiente têm precedência sobre ele.
+type File struct {
+    Path string
+    // WatchInterval é de quanto em quanto tempo o arquivo é relido; zero
+    // desliga o hot reload.
+    WatchInterval time.Duration
+}
+
+type Log struct {
+    // Level é o nível mínimo dos logs: debug, info, warn ou error.
+    Level string
+    // Components sobrescreve o nível por componente (worker, service,
+    // processor, webhook, http): LOG_COMPONENTS="worker=debug;processor=warn".
+    Components map[string]string
+    // A cada SamplingTick, por componente e mensagem, saem os SamplingFirst
+    // primeiros registros abaixo de error e, depois, um a cada
+    // SamplingThereafter. SamplingFirst zero desliga a amostragem.
+    SamplingFirst int
+    SamplingThereafter int
+    SamplingTick time.Duration
+    // Redact mascara atributos pela chave, sem diferenciar maiúsculas, "_" e
+    // "-": full troca o valor inteiro, partial mantém só o começo e o fim.
+    // LOG_REDACT="amount=off;email=full" é somado a defaultRedact; off
+    // remove uma chave padrão.
+    Redact map[string]string
 }
 
 type API struct {
+    // Port é a porta TCP; API_PORT=off deixa só o Unix socket.
     Port string
+    // Socket, se definido, é um Unix socket ouvido junto com a porta TCP,
+    // para o nginx no mesmo host sem passar pela pilha TCP. Pelo socket não
+    // há IP do cliente: o rate limit por IP depende do RATE_LIMIT_TRUST_PROXY.
+    Socket string
+    // SocketMode são as permissões do socket (API_SOCKET_MODE, em octal).
+    SocketMode os.FileMode
     BasePath string
+    ReadTimeout time.Duration
+    WriteTimeout time.Duration
+    IdleTimeout time.Duration
+    // ShutdownTimeout é o tempo total do graceful shutdown (HTTP e workers).
+    ShutdownTimeout time.Duration
 }
 
 type ExternalServices struct {
@@ -28,50 +89,669 @@ type ExternalServices struct {
 
 type ExternalService struct {
     BaseURL string
+    // FeeRate é a taxa cobrada por transação, usada para estimar custos no summary.
+    FeeRate float64
+    // Timeout de cada chamada HTTP ao processador.
+    Timeout time.Duration
+    // Refunds habilita os estornos, que vão para POST /payments/{id}/refunds.
+    // Os processadores da Rinha não têm esse endpoint.
+    Refunds bool
+    Auth ProcessorAuth
+}
+
+// ProcessorAuth é a autenticação das chamadas ao processador. Segredos vêm da
+// variável ou, com o sufixo _FILE, de um arquivo (ex.: secrets do Docker).
+type ProcessorAuth struct {
+    // Token vai no header X-Rinha-Token, exigido pelos endpoints /admin.
+    Token string `secret:"true"`
+    // HMACSecret assina o corpo de cada requisição com timestamp e nonce.
+    HMACSecret string `secret:"true"`
+    // ClientCert e ClientKey habilitam mTLS; CACert valida o certificado do
+    // processador quando ele não é assinado por uma CA pública.
+    ClientCert string
+    ClientKey string
+    CACert string
+}
+
+// TLSConfig carrega os certificados do mTLS. O loader chama na validação,
+// para que um arquivo ilegível impeça a instância de subir.
+func (a ProcessorAuth) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if a.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(a.ClientCert, a.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if a.CACert != "" {
+		pem, err := os.ReadFile(a.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("nenhum certificado em %s", a.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// Tenant tem os próprios processadores. Vem de TENANTS=<id>,<id> e, para cada
+// id, de TENANT_<ID>_DEFAULT_PAYMENT_PROCESSOR_URL e equivalentes; o que não
+// for definido herda a configuração global.
+type Tenant struct {
+    ID string
+    ExternalServices ExternalServices
+}
+
+type Payment struct {
+    // TimestampPolicy define quando o requestedAt é carimbado: "receipt" (ao
+    // receber o POST) ou "dispatch" (ao enviar para o processador).
+    TimestampPolicy string
+    // SlowProcessorThreshold: com o minResponseTime do default acima disso, os
+    // pagamentos vão para o fallback.
+    SlowProcessorThreshold time.Duration
+    // MaxConcurrency limita quantos workers processam pagamentos ao mesmo
+    // tempo; acima de Workers não tem efeito.
+    MaxConcurrency int
+    // EnqueueRetries são as tentativas de colocar o pagamento na fila cheia
+    // antes de marcá-lo como morto.
+    EnqueueRetries int
+    MaxSummaryWait time.Duration
+    // ScheduleLease é quanto tempo um pagamento agendado fica reservado para a
+    // instância que o reivindicou; ScheduleBatch, quantos são reivindicados
+    // por vez.
+    ScheduleLease time.Duration
+    ScheduleBatch int
+
+    Workers int
+    QueueSize int
+    ErrorQueueSize int
+    // Timeout de um pagamento no worker, incluindo a consulta ao Redis.
+    Timeout time.Duration
+    HealthCheckInterval time.Duration
+    HealthCheckTimeout time.Duration
+    SchedulerInterval time.Duration
+    // RetryInterval é a frequência com que a fila de erros é drenada em lote;
+    // RetryDelay e RetryBatchDelay são as esperas antes de devolver cada
+    // pagamento à fila principal, fora e dentro do lote.
+    RetryInterval time.Duration
+    RetryDelay time.Duration
+    RetryBatchDelay time.Duration
+    RetryBatch int
+    MetricsInterval time.Duration
+}
+
+type Webhook struct {
+    // Enabled liga o envio dos webhooks de conclusão (WEBHOOK_ENABLED). Ligado,
+    // exige o Secret: assinar com chave vazia parece assinado, mas não é.
+    Enabled bool
+    // Secret assina os webhooks enviados para o callbackUrl de cada pagamento.
+    // Webhooks de clientes usam o segredo gerado no cadastro.
+    Secret string `secret:"true"`
+}
+
+type Auth struct {
+    // Enabled exige API key nas rotas da API. Desligado, todas as rotas ficam
+    // abertas, como no ambiente da Rinha.
+    Enabled bool
+    // BootstrapKey é uma chave admin criada no start, para cadastrar as demais.
+    BootstrapKey string `secret:"true"`
+}
+
+// RateLimit limita requisições por API key (ou IP, sem chave) e por rota.
+// Limites vêm no formato "<rate>/<período>[:<burst>]", ex.: "100/s:200"; "off"
+// desliga o limite. RATE_LIMIT_ROUTES sobrescreve por rota, separando as regras
+// por ";": "POST /payments=500/s:1000;GET /payments=20/s".
+type RateLimit struct {
+    Enabled bool
+    // TrustProxy usa o X-Real-IP do nginx como IP do cliente.
+    TrustProxy bool
+    Default Limit
+    Routes map[string]Limit
+}
+
+// Limit permite Rate requisições por Period, acumulando até Burst. Rate zero
+// significa sem limite.
+type Limit struct {
+    Rate int
+    Period time.Duration
+    Burst int
 }
 
 type Redis struct {
     Host string
     Port int
-    Password string
+    // Socket, se definido, conecta pelo Unix socket no lugar de Host:Port.
+    Socket string
+    Password string `secret:"true"`
+}
+
+// ValidationError lista todos os valores inválidos encontrados na
+// configuração, para que tudo seja corrigido de uma vez.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "configuração inválida: " + strings.Join(msgs, "; ")
 }
 
-func newConfig() *Config {
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// Load lê a configuração uma única vez. Valores inválidos ficam com o padrão
+// na Config devolvida e são todos listados no *ValidationError; quem sobe a
+// aplicação deve parar nesse erro.
+func Load() (*Config, error) {
+	if c := cfg.Load(); c != nil {
+		return c, cfgErr
+	}
+	c, err := newConfig()
+	cfg.Store(c)
+	cfgErr = err
+	return c, err
+}
+
+// GetInstance devolve a configuração em vigor, sem o erro de validação, que
+// já foi tratado no Load do início da aplicação. Depois de um hot reload o
+// ponteiro muda: quem precisa dos tunables atualizados chama GetInstance a
+// cada uso em vez de guardar a Config.
+func GetInstance() *Config {
+	c, _ := Load()
+	return c
+}
+
+func newConfig() (*Config, error) {
     err := gotenv.Load()
 	if err != nil {
 		slog.Info("arquivo .env não encontrado, usando variáveis de ambiente")
 	}
-	
-	redisPort, err := strconv.Atoi(os.Getenv("REDIS_PORT"))
-	if err != nil {
-		slog.Error("erro ao converter REDIS_PORT para int", slog.Any("err", err), slog.Any("value", os.Getenv("REDIS_PORT")))
-		redisPort = 0
-	}
+	return load()
+}
+
+// load monta a Config a partir do ambiente, com o arquivo de CONFIG_FILE por
+// baixo das variáveis.
+func load() (*Config, error) {
+    l := &loader{}
+    path := os.Getenv("CONFIG_FILE")
+    if path != "" {
+        values, err := readFile(path)
+        if err != nil {
+            l.fail("CONFIG_FILE", err)
+        }
+        l.file = values
+    }
+
+    externalServices := ExternalServices{
+        DefaultPaymentProcessor: l.externalService("EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_", ExternalService{
+            BaseURL: "http://localhost:8001",
+            FeeRate: 0.05,
+            Timeout: 60 * time.Second,
+        }),
+        FallbackPaymentProcessor: l.externalService("EXTERNAL_SERVICE_FALLBACK_PAYMENT_PROCESSOR_", ExternalService{
+            BaseURL: "http://localhost:8002",
+            FeeRate: 0.15,
+            Timeout: 60 * time.Second,
+        }),
+    }
+
+    redisPort, _ := strconv.Atoi(l.envPort("REDIS_PORT", "6379"))
+
+    workers := l.envPositiveInt("PAYMENT_WORKERS", 20)
 
-    return &Config{
-        API: API{
-            Port: os.Getenv("API_PORT"),
-            BasePath: os.Getenv("API_BASE_PATH"),
+    c := &Config{
+        File: File{
+            Path: path,
+            WatchInterval: l.envDuration("CONFIG_WATCH_INTERVAL", 2*time.Second),
         },
+        Log: l.log(),
+        API: l.api(),
         Redis: Redis{
-            Host: os.Getenv("REDIS_HOST"),
+            Host: l.envString("REDIS_HOST", "localhost"),
             Port: redisPort,
-            Password: os.Getenv("REDIS_PASSWORD"),
+            Socket: l.envString("REDIS_SOCKET", ""),
+            Password: l.envSecret("REDIS_PASSWORD", ""),
         },
-        ExternalServices: ExternalServices{
-            DefaultPaymentProcessor: ExternalService{
-                BaseURL: os.Getenv("EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_URL"),
-            },
-            FallbackPaymentProcessor: ExternalService{
-                BaseURL: os.Getenv("EXTERNAL_SERVICE_FALLBACK_PAYMENT_PROCESSOR_URL"),
-            },
+        ExternalServices: externalServices,
+        Payment: Payment{
+            TimestampPolicy: l.envOneOf("PAYMENT_TIMESTAMP_POLICY", "receipt", "receipt", "dispatch"),
+            SlowProcessorThreshold: l.envPositiveDuration("PAYMENT_SLOW_PROCESSOR_THRESHOLD", 5*time.Second),
+            EnqueueRetries: l.envPositiveInt("PAYMENT_ENQUEUE_RETRIES", 3),
+            MaxSummaryWait: l.envPositiveDuration("PAYMENT_MAX_SUMMARY_WAIT", 5*time.Second),
+            ScheduleLease: l.envPositiveDuration("PAYMENT_SCHEDULE_LEASE", 30*time.Second),
+            ScheduleBatch: l.envPositiveInt("PAYMENT_SCHEDULE_BATCH", 100),
+            MaxConcurrency: l.envPositiveInt("PAYMENT_MAX_CONCURRENCY", workers),
+            Workers: workers,
+            QueueSize: l.envPositiveInt("PAYMENT_QUEUE_SIZE", 10000),
+            ErrorQueueSize: l.envPositiveInt("PAYMENT_ERROR_QUEUE_SIZE", 1000),
+            Timeout: l.envPositiveDuration("PAYMENT_TIMEOUT", 30*time.Second),
+            HealthCheckInterval: l.envPositiveDuration("PAYMENT_HEALTH_CHECK_INTERVAL", 8*time.Second),
+            HealthCheckTimeout: l.envPositiveDuration("PAYMENT_HEALTH_CHECK_TIMEOUT", 10*time.Second),
+            SchedulerInterval: l.envPositiveDuration("PAYMENT_SCHEDULER_INTERVAL", 250*time.Millisecond),
+            RetryInterval: l.envPositiveDuration("PAYMENT_RETRY_INTERVAL", 10*time.Second),
+            RetryDelay: l.envDuration("PAYMENT_RETRY_DELAY", 5*time.Second),
+            RetryBatchDelay: l.envDuration("PAYMENT_RETRY_BATCH_DELAY", time.Second),
+            RetryBatch: l.envPositiveInt("PAYMENT_RETRY_BATCH", 100),
+            MetricsInterval: l.envPositiveDuration("PAYMENT_METRICS_INTERVAL", time.Minute),
         },
+        Webhook: l.webhook(),
+        Auth: Auth{
+            Enabled: l.envBool("AUTH_ENABLED", true),
+            BootstrapKey: l.envSecret("AUTH_BOOTSTRAP_KEY", ""),
+        },
+        RateLimit: l.rateLimit(),
+        Tenants: l.tenants(externalServices),
+    }
+    if len(l.errs) > 0 {
+        return c, &ValidationError{Errors: l.errs}
     }
+    return c, nil
 }
 
-func GetInstance() *Config {
-	if cfg == nil {
-		cfg = newConfig()
+func (l *loader) api() API {
+	api := API{
+		Socket:          l.envString("API_SOCKET", ""),
+		SocketMode:      l.envFileMode("API_SOCKET_MODE", 0o660),
+		BasePath:        l.envString("API_BASE_PATH", ""),
+		ReadTimeout:     l.envPositiveDuration("API_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:    l.envPositiveDuration("API_WRITE_TIMEOUT", 15*time.Second),
+		IdleTimeout:     l.envPositiveDuration("API_IDLE_TIMEOUT", 60*time.Second),
+		ShutdownTimeout: l.envPositiveDuration("API_SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+	if l.get("API_PORT") != "off" {
+		api.Port = l.envPort("API_PORT", "8080")
+	} else if api.Socket == "" {
+		l.fail("API_PORT", errors.New("API_PORT=off exige API_SOCKET"))
+	}
+	return api
+}
+
+func (l *loader) webhook() Webhook {
+	w := Webhook{
+		Enabled: l.envBool("WEBHOOK_ENABLED", true),
+		Secret:  l.envSecret("WEBHOOK_SECRET", ""),
+	}
+	if w.Enabled && w.Secret == "" {
+		l.fail("WEBHOOK_SECRET", errors.New("obrigatório com WEBHOOK_ENABLED=true"))
+	}
+	return w
+}
+
+func (l *loader) tenants(defaults ExternalServices) []Tenant {
+	var tenants []Tenant
+	seen := make(map[string]bool)
+	for id := range strings.SplitSeq(l.envString("TENANTS", ""), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if seen[id] {
+			l.fail("TENANTS", fmt.Errorf("tenant %q repetido", id))
+			continue
+		}
+		seen[id] = true
+		prefix := "TENANT_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		tenants = append(tenants, Tenant{
+			ID: id,
+			ExternalServices: ExternalServices{
+				DefaultPaymentProcessor:  l.externalService(prefix+"DEFAULT_PAYMENT_PROCESSOR_", defaults.DefaultPaymentProcessor),
+				FallbackPaymentProcessor: l.externalService(prefix+"FALLBACK_PAYMENT_PROCESSOR_", defaults.FallbackPaymentProcessor),
+			},
+		})
+	}
+	return tenants
+}
+
+// externalService lê <prefix>URL, <prefix>FEE_RATE, <prefix>TIMEOUT,
+// <prefix>REFUNDS, <prefix>TOKEN, <prefix>HMAC_SECRET e os caminhos <prefix>CLIENT_CERT,
+// <prefix>CLIENT_KEY e <prefix>CA_CERT; o que não for definido fica com o
+// valor de def.
+func (l *loader) externalService(prefix string, def ExternalService) ExternalService {
+	svc := ExternalService{
+		BaseURL: l.envURL(prefix+"URL", def.BaseURL),
+		FeeRate: l.envFloat(prefix+"FEE_RATE", def.FeeRate),
+		Timeout: l.envPositiveDuration(prefix+"TIMEOUT", def.Timeout),
+		Refunds: l.envBool(prefix+"REFUNDS", def.Refunds),
+		Auth: ProcessorAuth{
+			Token:      l.envSecret(prefix+"TOKEN", def.Auth.Token),
+			HMACSecret: l.envSecret(prefix+"HMAC_SECRET", def.Auth.HMACSecret),
+			ClientCert: l.envString(prefix+"CLIENT_CERT", def.Auth.ClientCert),
+			ClientKey:  l.envString(prefix+"CLIENT_KEY", def.Auth.ClientKey),
+			CACert:     l.envString(prefix+"CA_CERT", def.Auth.CACert),
+		},
+	}
+	if svc.FeeRate < 0 || svc.FeeRate >= 1 {
+		l.fail(prefix+"FEE_RATE", fmt.Errorf("taxa %v fora de [0, 1)", svc.FeeRate))
+		svc.FeeRate = def.FeeRate
+	}
+	if (svc.Auth.ClientCert == "") != (svc.Auth.ClientKey == "") {
+		l.fail(prefix+"CLIENT_CERT", errors.New("CLIENT_CERT e CLIENT_KEY precisam ser definidos juntos"))
+	} else if _, err := svc.Auth.TLSConfig(); err != nil {
+		key := prefix + "CLIENT_CERT"
+		if svc.Auth.ClientCert == "" {
+			key = prefix + "CA_CERT"
+		}
+		l.fail(key, fmt.Errorf("certificados do mTLS não carregam: %w", err))
+	}
+	return svc
+}
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+func (l *loader) log() Log {
+	return Log{
+		Level:              l.envOneOf("LOG_LEVEL", "info", logLevels...),
+		Components:         l.logComponents(),
+		SamplingFirst:      l.envNonNegativeInt("LOG_SAMPLING_FIRST", 100),
+		SamplingThereafter: l.envNonNegativeInt("LOG_SAMPLING_THEREAFTER", 100),
+		SamplingTick:       l.envPositiveDuration("LOG_SAMPLING_TICK", time.Second),
+		Redact:             l.logRedact(),
+	}
+}
+
+func (l *loader) logComponents() map[string]string {
+	components := make(map[string]string)
+	for rule := range strings.SplitSeq(l.envString("LOG_COMPONENTS", ""), ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(rule, "=")
+		name, level = strings.TrimSpace(name), strings.TrimSpace(level)
+		if !ok || name == "" || !slices.Contains(logLevels, level) {
+			l.fail("LOG_COMPONENTS", fmt.Errorf("regra %q inválida, use <componente>=<%s>", rule, strings.Join(logLevels, "|")))
+			continue
+		}
+		components[name] = level
+	}
+	return components
+}
+
+var redactModes = []string{"full", "partial", "off"}
+
+var defaultRedact = map[string]string{
+	"correlationId":  "partial",
+	"amount":         "full",
+	"totalAmount":    "full",
+	"refundedAmount": "full",
+	"token":          "full",
+	"secret":         "full",
+	"password":       "full",
+	"authorization":  "full",
+	"cookie":         "full",
+	"x-api-key":      "full",
+	"x-rinha-token":  "full",
+	"x-signature":    "full",
+}
+
+func (l *loader) logRedact() map[string]string {
+	redact := maps.Clone(defaultRedact)
+	for rule := range strings.SplitSeq(l.envString("LOG_REDACT", ""), ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		key, mode, ok := strings.Cut(rule, "=")
+		key, mode = strings.TrimSpace(key), strings.TrimSpace(mode)
+		if !ok || key == "" || !slices.Contains(redactModes, mode) {
+			l.fail("LOG_REDACT", fmt.Errorf("regra %q inválida, use <chave>=<%s>", rule, strings.Join(redactModes, "|")))
+			continue
+		}
+		maps.DeleteFunc(redact, func(k, _ string) bool { return RedactKey(k) == RedactKey(key) })
+		if mode != "off" {
+			redact[key] = mode
+		}
+	}
+	return redact
+}
+
+// RedactKey normaliza uma chave de LOG_REDACT: correlationId, correlation_id
+// e Correlation-ID são a mesma chave.
+func RedactKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+var defaultRouteLimits = map[string]string{
+	"POST /payments":       "500/s:1000",
+	"POST /payments/batch": "10/s:20",
+	"GET /livez":           "off",
+	"GET /readyz":          "off",
+}
+
+func (l *loader) rateLimit() RateLimit {
+	rl := RateLimit{
+		Enabled:    l.envBool("RATE_LIMIT_ENABLED", true),
+		TrustProxy: l.envBool("RATE_LIMIT_TRUST_PROXY", false),
+		Default:    l.envLimit("RATE_LIMIT_DEFAULT", "100/s:200"),
+		Routes:     make(map[string]Limit),
+	}
+	for route, v := range defaultRouteLimits {
+		lim, _ := ParseLimit(v)
+		rl.Routes[route] = lim
+	}
+	for rule := range strings.SplitSeq(l.envString("RATE_LIMIT_ROUTES", ""), ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		route, v, ok := strings.Cut(rule, "=")
+		if !ok {
+			l.fail("RATE_LIMIT_ROUTES", fmt.Errorf("regra %q sem \"=\"", rule))
+			continue
+		}
+		lim, err := ParseLimit(v)
+		if err != nil {
+			l.fail("RATE_LIMIT_ROUTES", err)
+			continue
+		}
+		rl.Routes[strings.Join(strings.Fields(route), " ")] = lim
+	}
+	return rl
+}
+
+// ParseLimit lê "<rate>/<período>[:<burst>]". O período aceita uma duração
+// ("10s") ou só a unidade ("s" = "1s"); sem burst, o burst é o próprio rate.
+func ParseLimit(v string) (Limit, error) {
+	v = strings.TrimSpace(v)
+	if v == "off" {
+		return Limit{}, nil
+	}
+
+	rate, window, ok := strings.Cut(v, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limite %q sem período", v)
+	}
+	window, burst, hasBurst := strings.Cut(window, ":")
+
+	l := Limit{}
+	var err error
+	if l.Rate, err = strconv.Atoi(rate); err != nil || l.Rate <= 0 {
+		return Limit{}, fmt.Errorf("rate inválido em %q", v)
 	}
-	return cfg
-}
\ No newline at end of file
+	if window != "" && (window[0] < '0' || window[0] > '9') {
+		window = "1" + window
+	}
+	if l.Period, err = time.ParseDuration(window); err != nil || l.Period <= 0 {
+		return Limit{}, fmt.Errorf("período inválido em %q", v)
+	}
+	l.Burst = l.Rate
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("burst inválido em %q", v)
+		}
+	}
+	return l, nil
+}
+
+// loader lê as variáveis de ambiente acumulando os erros: um valor inválido
+// fica com o padrão e o erro é listado no ValidationError. Variáveis não
+// definidas são procuradas no arquivo de configuração.
+type loader struct {
+	file map[string]string
+	errs []error
+}
+
+func (l *loader) get(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return l.file[key]
+}
+
+func (l *loader) fail(key string, err error) {
+	l.errs = append(l.errs, fmt.Errorf("%s: %w", key, err))
+}
+
+func (l *loader) envString(key, def string) string {
+	if v := l.get(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envSecret lê key ou, se ela não existir, o arquivo apontado por key_FILE.
+func (l *loader) envSecret(key, def string) string {
+	if v := l.get(key); v != "" {
+		return v
+	}
+	path := l.get(key + "_FILE")
+	if path == "" {
+		return def
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		l.fail(key+"_FILE", err)
+		return def
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func (l *loader) envOneOf(key, def string, allowed ...string) string {
+	v := l.envString(key, def)
+	if !slices.Contains(allowed, v) {
+		l.fail(key, fmt.Errorf("%q não é um de %s", v, strings.Join(allowed, ", ")))
+		return def
+	}
+	return v
+}
+
+func (l *loader) envPort(key, def string) string {
+	v := l.envString(key, def)
+	if p, err := strconv.Atoi(v); err != nil || p <= 0 || p > 65535 {
+		l.fail(key, fmt.Errorf("porta inválida %q", v))
+		return def
+	}
+	return v
+}
+
+func (l *loader) envFileMode(key string, def os.FileMode) os.FileMode {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	m, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || m > 0o777 {
+		l.fail(key, fmt.Errorf("permissão inválida %q, use octal (ex.: 0660)", v))
+		return def
+	}
+	return os.FileMode(m)
+}
+
+func (l *loader) envURL(key, def string) string {
+	v := l.envString(key, def)
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		l.fail(key, fmt.Errorf("URL inválida %q", v))
+		return def
+	}
+	return v
+}
+
+func (l *loader) envLimit(key, def string) Limit {
+	lim, err := ParseLimit(l.envString(key, def))
+	if err != nil {
+		l.fail(key, err)
+		lim, _ = ParseLimit(def)
+	}
+	return lim
+}
+
+func (l *loader) envBool(key string, def bool) bool {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.fail(key, fmt.Errorf("bool inválido %q", v))
+		return def
+	}
+	return b
+}
+
+func (l *loader) envFloat(key string, def float64) float64 {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		l.fail(key, fmt.Errorf("número inválido %q", v))
+		return def
+	}
+	return f
+}
+
+func (l *loader) envPositiveInt(key string, def int) int {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		l.fail(key, fmt.Errorf("inteiro positivo inválido %q", v))
+		return def
+	}
+	return n
+}
+
+func (l *loader) envNonNegativeInt(key string, def int) int {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		l.fail(key, fmt.Errorf("inteiro não negativo inválido %q", v))
+		return def
+	}
+	return n
+}
+
+// envDuration aceita o formato do time.ParseDuration ("250ms", "1m30s").
+func (l *loader) envDuration(key string, def time.Duration) time.Duration {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		l.fail(key, fmt.Errorf("duração inválida %q", v))
+		return def
+	}
+	return d
+}
+
+func (l *loader) envPositiveDuration(key string, def time.Duration) time.Duration {
+	d := l.envDuration(key, def)
+	if d == 0 {
+		l.fail(key, errors.New("a duração precisa ser maior que zero"))
+		return def
+	}
+	return d
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// Obrigatório com os webhooks ligados (padrão); os testes que precisam
+	// dele vazio usam t.Setenv.
+	os.Setenv("WEBHOOK_SECRET", "test-secret")
+	os.Exit(m.Run())
+}
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("100/s:200")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 100, Period: time.Second, Burst: 200}, l)
+
+	l, err = ParseLimit("30/10m")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Rate: 30, Period: 10 * time.Minute, Burst: 30}, l)
+
+	l, err = ParseLimit("off")
+	assert.NoError(t, err)
+	assert.Zero(t, l.Rate)
+
+	for _, v := range []string{"100", "0/s", "10/x", "10/s:-1", "abc/s"} {
+		_, err = ParseLimit(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestNewConfig_Defaults(t *testing.T) {
+	c, err := newConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "8080", c.API.Port)
+	assert.Equal(t, 6379, c.Redis.Port)
+	assert.Equal(t, "receipt", c.Payment.TimestampPolicy)
+	assert.Equal(t, 20, c.Payment.Workers)
+	assert.Equal(t, 30*time.Second, c.Payment.Timeout)
+	assert.Equal(t, 60*time.Second, c.ExternalServices.DefaultPaymentProcessor.Timeout)
+}
+
+func TestNewConfig_ListsEveryError(t *testing.T) {
+	t.Setenv("REDIS_PORT", "abc")
+	t.Setenv("PAYMENT_WORKERS", "-1")
+	t.Setenv("PAYMENT_TIMEOUT", "0s")
+	t.Setenv("PAYMENT_TIMESTAMP_POLICY", "later")
+	t.Setenv("EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_URL", "payment-processor:8080")
+	t.Setenv("EXTERNAL_SERVICE_FALLBACK_PAYMENT_PROCESSOR_CLIENT_CERT", "/certs/client.pem")
+	t.Setenv("RATE_LIMIT_ROUTES", "POST /payments=fast")
+	t.Setenv("LOG_COMPONENTS", "worker=debug;processor=loud")
+
+	c, err := newConfig()
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Errors, 8)
+	for _, key := range []string{"REDIS_PORT", "PAYMENT_WORKERS", "PAYMENT_TIMEOUT", "PAYMENT_TIMESTAMP_POLICY", "EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_URL", "EXTERNAL_SERVICE_FALLBACK_PAYMENT_PROCESSOR_CLIENT_CERT", "RATE_LIMIT_ROUTES", "LOG_COMPONENTS"} {
+		assert.Contains(t, err.Error(), key)
+	}
+
+	// Os valores inválidos ficam com o padrão.
+	assert.Equal(t, 6379, c.Redis.Port)
+	assert.Equal(t, 20, c.Payment.Workers)
+	assert.Equal(t, 30*time.Second, c.Payment.Timeout)
+	assert.Equal(t, map[string]string{"worker": "debug"}, c.Log.Components)
+}
+
+func TestNewConfig_ProcessorCertificates(t *testing.T) {
+	t.Setenv("EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_CLIENT_CERT", filepath.Join(t.TempDir(), "client.pem"))
+	t.Setenv("EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_CLIENT_KEY", filepath.Join(t.TempDir(), "client-key.pem"))
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(ca, []byte("not a certificate"), 0o600))
+	t.Setenv("EXTERNAL_SERVICE_FALLBACK_PAYMENT_PROCESSOR_CA_CERT", ca)
+
+	_, err := newConfig()
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Errors, 2)
+	assert.ErrorContains(t, err, "EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_CLIENT_CERT")
+	assert.ErrorContains(t, err, "EXTERNAL_SERVICE_FALLBACK_PAYMENT_PROCESSOR_CA_CERT")
+}
+
+func TestNewConfig_WebhookSecret(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "")
+	_, err := newConfig()
+	assert.ErrorContains(t, err, "WEBHOOK_SECRET")
+
+	t.Setenv("WEBHOOK_ENABLED", "false")
+	_, err = newConfig()
+	assert.NoError(t, err)
+}
+
+func TestNewConfig_Redact(t *testing.T) {
+	t.Setenv("LOG_REDACT", "amount=off;correlation_id=full;email=partial")
+
+	c, err := newConfig()
+	require.NoError(t, err)
+	assert.NotContains(t, c.Log.Redact, "amount")
+	assert.NotContains(t, c.Log.Redact, "correlationId")
+	assert.Equal(t, "full", c.Log.Redact["correlation_id"])
+	assert.Equal(t, "partial", c.Log.Redact["email"])
+	assert.Equal(t, "full", c.Log.Redact["authorization"])
+
+	t.Setenv("LOG_REDACT", "amount=hidden")
+	_, err = newConfig()
+	assert.ErrorContains(t, err, "LOG_REDACT")
+}
+
+func TestNewConfig_UnixSocket(t *testing.T) {
+	t.Setenv("API_PORT", "off")
+	_, err := newConfig()
+	assert.ErrorContains(t, err, "API_SOCKET")
+
+	t.Setenv("API_SOCKET", "/sockets/api.sock")
+	t.Setenv("API_SOCKET_MODE", "0666")
+	t.Setenv("REDIS_SOCKET", "/sockets/redis.sock")
+	c, err := newConfig()
+	require.NoError(t, err)
+	assert.Empty(t, c.API.Port)
+	assert.Equal(t, os.FileMode(0o666), c.API.SocketMode)
+	assert.Equal(t, "/sockets/redis.sock", c.Redis.Socket)
+	assert.Equal(t, "0666", Redacted(c)["api"].(map[string]any)["socketMode"])
+
+	t.Setenv("API_SOCKET_MODE", "rw")
+	_, err = newConfig()
+	assert.ErrorContains(t, err, "API_SOCKET_MODE")
+}
+
+func TestLoadBalancer(t *testing.T) {
+	_, err := LoadBalancer()
+	assert.ErrorContains(t, err, "nenhum upstream")
+
+	t.Setenv("LB_UPSTREAMS", "unix:/sockets/api1.sock, api2:8080")
+	c, err := LoadBalancer()
+	require.NoError(t, err)
+	assert.Equal(t, []Upstream{{Network: "unix", Address: "/sockets/api1.sock"}, {Network: "tcp", Address: "api2:8080"}}, c.Upstreams)
+	assert.Equal(t, "/readyz", c.HealthPath)
+	assert.Equal(t, "9999", c.Port)
+
+	t.Setenv("LB_UPSTREAMS", "unix:,api2")
+	t.Setenv("LB_HEALTH_PATH", "readyz")
+	_, err = LoadBalancer()
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Errors, 3)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_FileUnderEnv(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+payment:
+  workers: 40
+  slow_processor_threshold: 2s
+external_service:
+  default_payment_processor:
+    url: http://pp-default:8080
+tenants: [acme]
+rate_limit:
+  routes:
+    POST /payments: 50/s
+`)
+	tomlFile := writeFile(t, "config.toml", `
+tenants = ["acme"]
+
+[payment]
+workers = 40
+slow_processor_threshold = "2s"
+
+[external_service.default_payment_processor]
+url = "http://pp-default:8080"
+
+[rate_limit.routes]
+"POST /payments" = "50/s"
+`)
+
+	for _, path := range []string{yamlFile, tomlFile} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", path)
+			t.Setenv("PAYMENT_WORKERS", "8")
+
+			c, err := load()
+			require.NoError(t, err)
+			assert.Equal(t, 8, c.Payment.Workers, "env sobrescreve o arquivo")
+			assert.Equal(t, 2*time.Second, c.Payment.SlowProcessorThreshold)
+			assert.Equal(t, "http://pp-default:8080", c.ExternalServices.DefaultPaymentProcessor.BaseURL)
+			assert.Equal(t, "http://pp-default:8080", c.Tenants[0].ExternalServices.DefaultPaymentProcessor.BaseURL)
+			assert.Equal(t, Limit{Rate: 50, Period: time.Second, Burst: 50}, c.RateLimit.Routes["POST /payments"])
+		})
+	}
+
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.json", `{}`))
+	_, err := load()
+	assert.ErrorContains(t, err, "CONFIG_FILE")
+}
+
+func TestReload_AppliesOnlyTunables(t *testing.T) {
+	path := writeFile(t, "config.yaml", "payment:\n  workers: 20\n")
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("REDIS_PASSWORD", "s3cret")
+	cur, err := load()
+	require.NoError(t, err)
+	prev := cfg.Swap(cur)
+	t.Cleanup(func() { cfg.Store(prev) })
+
+	var notified *Config
+	OnReload(func(c *Config) { notified = c })
+
+	require.NoError(t, os.WriteFile(path, []byte("log:\n  level: debug\npayment:\n  workers: 40\n  max_concurrency: 5\n"), 0o600))
+	reload()
+
+	c := GetInstance()
+	assert.Same(t, c, notified)
+	assert.Equal(t, "debug", c.Log.Level)
+	assert.Equal(t, 5, c.Payment.MaxConcurrency)
+	assert.Equal(t, 20, c.Payment.Workers, "workers só mudam com restart")
+
+	require.NoError(t, os.WriteFile(path, []byte("payment:\n  workers: abc\n"), 0o600))
+	reload()
+	assert.Same(t, c, GetInstance(), "arquivo inválido mantém a configuração")
+}
+
+func TestRedacted(t *testing.T) {
+	c := &Config{
+		Redis:   Redis{Host: "redis", Password: "s3cret"},
+		Payment: Payment{Timeout: 30 * time.Second},
+	}
+	v := Redacted(c)
+	assert.Equal(t, "[REDACTED]", v["redis"].(map[string]any)["password"])
+	assert.Equal(t, "redis", v["redis"].(map[string]any)["host"])
+	assert.Equal(t, "30s", v["payment"].(map[string]any)["timeout"])
+	assert.Equal(t, "", v["webhook"].(map[string]any)["secret"])
+	assert.NotContains(t, fmt.Sprint(v), "s3cret")
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile lê um arquivo YAML ou TOML e o achata nos nomes das variáveis de
+// ambiente, para que o loader trate as duas fontes do mesmo jeito:
+//
+//	payment:
+//	  workers: 20                  # PAYMENT_WORKERS=20
+//	external_service:
+//	  default_payment_processor:
+//	    url: http://pp:8080        # EXTERNAL_SERVICE_DEFAULT_PAYMENT_PROCESSOR_URL
+//	tenants: [acme, globex]        # TENANTS=acme,globex
+//	rate_limit:
+//	  routes:
+//	    POST /payments: 500/s:1000 # RATE_LIMIT_ROUTES=POST /payments=500/s:1000
+//	log:
+//	  components:
+//	    worker: debug              # LOG_COMPONENTS=worker=debug
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("formato %q não suportado, use .yaml, .yml ou .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten(values, "", doc)
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		if prefix == "RATE_LIMIT_ROUTES" || prefix == "LOG_COMPONENTS" || prefix == "LOG_REDACT" {
+			rules := make([]string, 0, len(v))
+			for route, limit := range v {
+				rules = append(rules, route+"="+fmt.Sprint(limit))
+			}
+			slices.Sort(rules)
+			values[prefix] = strings.Join(rules, ";")
+			return
+		}
+		for k, child := range v {
+			key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			flatten(values, key, child)
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+	default:
+		values[prefix] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	listenersMu sync.Mutex
+	listeners   []func(*Config)
+)
+
+// OnReload registra fn para ser chamada com a nova Config a cada hot reload
+// aplicado.
+func OnReload(fn func(*Config)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Watch relê o arquivo de configuração a cada File.WatchInterval até o ctx
+// acabar. Só os tunables (veja applyTunables) mudam em runtime; o resto
+// continua valendo até o restart.
+func Watch(ctx context.Context) {
+	f := GetInstance().File
+	if f.Path == "" || f.WatchInterval == 0 {
+		return
+	}
+
+	last, _ := os.ReadFile(f.Path)
+	ticker := time.NewTicker(f.WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b, err := os.ReadFile(f.Path)
+			if err != nil || bytes.Equal(b, last) {
+				continue
+			}
+			last = b
+			reload()
+		}
+	}
+}
+
+func reload() {
+	cur := GetInstance()
+	next, err := load()
+	if err != nil {
+		slog.Error("hot reload da configuração recusado", slog.String("file", cur.File.Path), slog.Any("err", err))
+		return
+	}
+
+	merged := applyTunables(*cur, next)
+	if pending := diff(&merged, next); len(pending) > 0 {
+		slog.Warn("mudanças na configuração que só valem após restart", slog.String("file", cur.File.Path), slog.Any("settings", pending))
+	}
+	changes := diff(cur, &merged)
+	if len(changes) == 0 {
+		return
+	}
+
+	cfg.Store(&merged)
+	slog.Info("configuração recarregada", slog.String("file", cur.File.Path), slog.Any("changes", changes))
+
+	listenersMu.Lock()
+	fns := slices.Clone(listeners)
+	listenersMu.Unlock()
+	for _, fn := range fns {
+		fn(&merged)
+	}
+}
+
+// applyTunables copia de next para cur o que pode mudar sem reiniciar: nível
+// de log, limiar de roteamento para o fallback, concorrência dos workers e
+// rate limits. Portas, URLs, tamanhos de fila e afins exigem restart.
+func applyTunables(cur Config, next *Config) Config {
+	cur.Log = next.Log
+	cur.Payment.SlowProcessorThreshold = next.Payment.SlowProcessorThreshold
+	cur.Payment.MaxConcurrency = next.Payment.MaxConcurrency
+	cur.RateLimit = next.RateLimit
+	return cur
+}
+
+// diff lista as chaves que mudaram de a para b no formato
+// "payment.workers: 20 -> 40", já sem segredos.
+func diff(a, b *Config) []string {
+	before, after := map[string]string{}, map[string]string{}
+	flattenView(before, "", Redacted(a))
+	flattenView(after, "", Redacted(b))
+
+	var changes []string
+	for _, k := range sortedKeys(before, after) {
+		if before[k] == after[k] {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", k, before[k], after[k]))
+	}
+	return changes
+}
+
+func sortedKeys(maps ...map[string]string) []string {
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func flattenView(out map[string]string, prefix string, v any) {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			flattenView(out, join(k), child)
+		}
+	case []any:
+		for i, child := range v {
+			flattenView(out, join(fmt.Sprint(i)), child)
+		}
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
+
+// Redacted devolve a configuração como mapa, com as chaves em camelCase,
+// durações legíveis ("5s"), permissões em octal ("0660") e os campos com a
+// tag secret:"true" mascarados.
+func Redacted(c *Config) map[string]any {
+	return view(reflect.ValueOf(*c), false).(map[string]any)
+}
+
+func view(v reflect.Value, secret bool) any {
+	if secret {
+		if v.IsZero() {
+			return ""
+		}
+		return redacted
+	}
+	switch x := v.Interface().(type) {
+	case time.Duration:
+		return x.String()
+	case os.FileMode:
+		return fmt.Sprintf("%#o", x)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]any, v.NumField())
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			m[camel(f.Name)] = view(v.Field(i), f.Tag.Get("secret") == "true")
+		}
+		return m
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		for _, k := range v.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = view(v.MapIndex(k), false)
+		}
+		return m
+	case reflect.Slice:
+		s := make([]any, v.Len())
+		for i := range v.Len() {
+			s[i] = view(v.Index(i), false)
+		}
+		return s
+	default:
+		return v.Interface()
+	}
+}
+
+// camel converte nomes de campo Go em camelCase: "API" -> "api",
+// "BaseURL" -> "baseURL", "HMACSecret" -> "hmacSecret".
+func camel(name string) string {
+	r := []rune(name)
+	for i := range r {
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		if !unicode.IsUpper(r[i]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
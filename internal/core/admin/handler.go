@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/apikey"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+// LogLevels são os níveis em vigor. Dropped conta os registros descartados
+// pela amostragem desde o start.
+type LogLevels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+	Dropped    uint64            `json:"dropped"`
+}
+
+type LogLevelsParams struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+type Handler struct{}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// getConfig mostra a configuração em vigor, já com os tunables do último hot
+// reload, e os segredos mascarados.
+func (h *Handler) getConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(config.Redacted(config.GetInstance()))
+}
+
+func (h *Handler) getLogLevels(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(currentLogLevels())
+}
+
+// putLogLevels troca os níveis na instância que recebeu a requisição, até o
+// próximo restart ou hot reload do arquivo de configuração. Sem "level", o
+// nível global não muda; componentes fora de "components" voltam ao global.
+func (h *Handler) putLogLevels(w http.ResponseWriter, r *http.Request) {
+	var params LogLevelsParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusBadRequest, "invalid log levels data", err))
+		return
+	}
+	if params.Level == "" {
+		params.Level, _ = logger.Levels()
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(params.Level)); err != nil {
+		xerror.Write(w, r, xerror.NewValidationError("level", "level must be debug, info, warn or error", err))
+		return
+	}
+	if err := logger.SetLevels(params.Level, params.Components); err != nil {
+		xerror.Write(w, r, xerror.NewValidationError("components", "component levels must be debug, info, warn or error", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(currentLogLevels())
+}
+
+func currentLogLevels() LogLevels {
+	level, components := logger.Levels()
+	return LogLevels{Level: level, Components: components, Dropped: logger.Dropped()}
+}
+
+func SetupRoutes(r *chi.Mux) {
+	handler := NewHandler()
+	admin := r.With(apikey.RequireGlobalAdmin())
+	admin.Get("/admin/config", handler.getConfig)
+	admin.Get("/admin/log-levels", handler.getLogLevels)
+	admin.Put("/admin/log-levels", handler.putLogLevels)
+}
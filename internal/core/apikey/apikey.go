@@ -0,0 +1,68 @@
+package apikey
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+type Scope string
+
+const (
+	// ScopeSubmit cria pagamentos, estornos e cancelamentos.
+	ScopeSubmit Scope = "submit"
+	// ScopeRead consulta pagamentos, summaries e eventos.
+	ScopeRead Scope = "read"
+	// ScopeAdmin inclui os demais, gerencia chaves e dispara health checks.
+	ScopeAdmin Scope = "admin"
+)
+
+var scopes = []Scope{ScopeSubmit, ScopeRead, ScopeAdmin}
+
+func (s Scope) Valid() bool {
+	return slices.Contains(scopes, s)
+}
+
+// Key é uma API key cadastrada. O segredo nunca é gravado: só o SHA-256 dele
+// e um prefixo curto, para que o dono reconheça a chave na listagem.
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []Scope   `json:"scopes"`
+	TenantID  string    `json:"tenantId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	RevokedAt time.Time `json:"revokedAt,omitzero"`
+}
+
+func (k Key) Allows(scope Scope) bool {
+	return slices.Contains(k.Scopes, ScopeAdmin) || slices.Contains(k.Scopes, scope)
+}
+
+func (k Key) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+type CreateKeyParams struct {
+	Name     string  `json:"name"`
+	Scopes   []Scope `json:"scopes"`
+	TenantID string  `json:"tenantId,omitempty"`
+}
+
+// CreatedKey é devolvido só na criação, única vez em que o segredo aparece.
+type CreatedKey struct {
+	Key
+	Secret string `json:"key"`
+}
+
+type contextKey struct{}
+
+func WithKey(ctx context.Context, k Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, k)
+}
+
+// FromContext devolve a chave autenticada da requisição.
+func FromContext(ctx context.Context) (Key, bool) {
+	k, ok := ctx.Value(contextKey{}).(Key)
+	return k, ok
+}
@@ -0,0 +1,30 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+var (
+	ErrKeyNotFound   = errors.New("api key not found")
+	ErrInvalidKey    = errors.New("invalid or revoked api key")
+	ErrMissingKey    = errors.New("missing api key")
+	ErrForbidden     = errors.New("api key does not have the required scope")
+	ErrTenantDenied  = errors.New("api key is not allowed to access this tenant")
+	ErrGlobalAdmin   = errors.New("operation requires an admin key not bound to a tenant")
+	ErrInvalidName   = errors.New("name must have between 1 and 100 characters")
+	ErrInvalidScopes = errors.New("scopes must be a non-empty list of submit, read or admin")
+)
+
+func init() {
+	xerror.Register(ErrKeyNotFound, xerror.Mapping{Status: http.StatusNotFound, Code: "api_key_not_found"})
+	xerror.Register(ErrInvalidKey, xerror.Mapping{Status: http.StatusUnauthorized, Code: "invalid_api_key"})
+	xerror.Register(ErrMissingKey, xerror.Mapping{Status: http.StatusUnauthorized, Code: "missing_api_key"})
+	xerror.Register(ErrForbidden, xerror.Mapping{Status: http.StatusForbidden, Code: "insufficient_scope"})
+	xerror.Register(ErrTenantDenied, xerror.Mapping{Status: http.StatusForbidden, Code: "tenant_denied"})
+	xerror.Register(ErrGlobalAdmin, xerror.Mapping{Status: http.StatusForbidden, Code: "global_admin_required"})
+	xerror.Register(ErrInvalidName, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_name", Field: "name"})
+	xerror.Register(ErrInvalidScopes, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_scopes", Field: "scopes"})
+}
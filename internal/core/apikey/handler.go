@@ -0,0 +1,81 @@
+package apikey
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+func (h *Handler) postKey(w http.ResponseWriter, r *http.Request) {
+	var params CreateKeyParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusBadRequest, "invalid api key data", err))
+		return
+	}
+
+	k, err := h.service.CreateKey(r.Context(), params)
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(k)
+}
+
+func (h *Handler) listKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListKeys(r.Context())
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *Handler) getKey(w http.ResponseWriter, r *http.Request) {
+	k, err := h.service.FindKey(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(k)
+}
+
+func (h *Handler) deleteKey(w http.ResponseWriter, r *http.Request) {
+	k, err := h.service.RevokeKey(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(k)
+}
+
+// SetupRoutes registra o gerenciamento de chaves. known diz quais tenants
+// estão cadastrados.
+func SetupRoutes(r *chi.Mux, db *database.Redis, known func(id string) bool) {
+	handler := NewHandler(NewService(NewRepository(db), known))
+	admin := r.With(RequireGlobalAdmin())
+	admin.Post("/admin/api-keys", handler.postKey)
+	admin.Get("/admin/api-keys", handler.listKeys)
+	admin.Get("/admin/api-keys/{id}", handler.getKey)
+	admin.Delete("/admin/api-keys/{id}", handler.deleteKey)
+}
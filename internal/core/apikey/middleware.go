@@ -0,0 +1,105 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+// Header é a alternativa ao "Authorization: Bearer <key>".
+const Header = "X-API-Key"
+
+type disabledKey struct{}
+
+// Middleware autentica a API key da requisição e a coloca no contexto. A
+// exigência de escopo fica com Require, por rota. Chaves ligadas a um tenant
+// definem o X-Tenant-Id; só chaves admin escolhem o tenant pelo header.
+// Com enabled=false nada é exigido.
+func Middleware(s *Service, enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !enabled {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), disabledKey{}, true)))
+				return
+			}
+
+			secret := secretFromRequest(r)
+			if secret == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			k, err := s.Authenticate(r.Context(), secret)
+			if err != nil {
+				xerror.Write(w, r, err)
+				return
+			}
+
+			if !k.Allows(ScopeAdmin) || k.TenantID != "" {
+				if h := r.Header.Get(tenant.Header); h != "" && h != k.TenantID {
+					xerror.Write(w, r, ErrTenantDenied)
+					return
+				}
+				if k.TenantID == "" {
+					r.Header.Del(tenant.Header)
+				} else {
+					r.Header.Set(tenant.Header, k.TenantID)
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), k)))
+		})
+	}
+}
+
+// Require responde 401 sem chave válida e 403 se a chave não tiver o escopo.
+func Require(scope Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if disabled, _ := r.Context().Value(disabledKey{}).(bool); disabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			k, ok := FromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				xerror.Write(w, r, ErrMissingKey)
+				return
+			}
+			if !k.Allows(scope) {
+				xerror.Write(w, r, ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireGlobalAdmin é o Require(ScopeAdmin) das rotas que valem para todos os
+// tenants (chaves, configuração, log e health dos processadores): uma chave
+// admin ligada a um tenant não passa.
+func RequireGlobalAdmin() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return Require(ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if k, ok := FromContext(r.Context()); ok && k.TenantID != "" {
+				xerror.Write(w, r, ErrGlobalAdmin)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+func secretFromRequest(r *http.Request) string {
+	if v := r.Header.Get(Header); v != "" {
+		return v
+	}
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRepository struct {
+	keys   map[string]Key
+	hashes map[string]string
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{keys: map[string]Key{}, hashes: map[string]string{}}
+}
+
+func (m *memoryRepository) SaveKey(_ context.Context, k Key, hash string) error {
+	m.keys[k.ID] = k
+	m.hashes[hash] = k.ID
+	return nil
+}
+
+func (m *memoryRepository) FindKey(_ context.Context, id string) (Key, error) {
+	k, ok := m.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return k, nil
+}
+
+func (m *memoryRepository) FindKeyByHash(ctx context.Context, hash string) (Key, error) {
+	id, ok := m.hashes[hash]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return m.FindKey(ctx, id)
+}
+
+func (m *memoryRepository) ListKeys(_ context.Context) ([]Key, error) {
+	keys := make([]Key, 0, len(m.keys))
+	for _, k := range m.keys {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (m *memoryRepository) RevokeKey(_ context.Context, id string, at time.Time) (Key, error) {
+	k, ok := m.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	k.RevokedAt = at
+	m.keys[id] = k
+	for hash, owner := range m.hashes {
+		if owner == id {
+			delete(m.hashes, hash)
+		}
+	}
+	return k, nil
+}
+
+func knownTenants(id string) bool {
+	return id == "acme" || id == "globex"
+}
+
+func serve(t *testing.T, s *Service, enabled bool, scope Scope, header http.Header) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	var gotTenant string
+	h := Middleware(s, enabled)(Require(scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = r.Header.Get(tenant.Header)
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+	for k, v := range header {
+		req.Header.Set(k, v[0])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, gotTenant
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(newMemoryRepository(), knownTenants)
+
+	reader, err := s.CreateKey(ctx, CreateKeyParams{Name: "reader", Scopes: []Scope{ScopeRead}, TenantID: "acme"})
+	require.NoError(t, err)
+	require.NoError(t, s.Bootstrap(ctx, "rk_bootstrap"))
+
+	rec, _ := serve(t, s, false, ScopeAdmin, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec, _ = serve(t, s, true, ScopeRead, nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+
+	rec, _ = serve(t, s, true, ScopeRead, http.Header{Header: {"rk_unknown"}})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec, gotTenant := serve(t, s, true, ScopeRead, http.Header{"Authorization": {"Bearer " + reader.Secret}})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "acme", gotTenant)
+
+	rec, _ = serve(t, s, true, ScopeSubmit, http.Header{Header: {reader.Secret}})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec, _ = serve(t, s, true, ScopeRead, http.Header{Header: {reader.Secret}, tenant.Header: {"other"}})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec, gotTenant = serve(t, s, true, ScopeSubmit, http.Header{Header: {"rk_bootstrap"}, tenant.Header: {"other"}})
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "other", gotTenant)
+
+	// Chave admin de um tenant não passa nas rotas globais.
+	tenantAdmin, err := s.CreateKey(ctx, CreateKeyParams{Name: "acme-admin", Scopes: []Scope{ScopeAdmin}, TenantID: "acme"})
+	require.NoError(t, err)
+	global := func(header http.Header) int {
+		h := Middleware(s, true)(RequireGlobalAdmin()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+		req := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, global(http.Header{Header: {tenantAdmin.Secret}}))
+	assert.Equal(t, http.StatusNoContent, global(http.Header{Header: {"rk_bootstrap"}}))
+
+	_, err = s.RevokeKey(ctx, reader.ID)
+	require.NoError(t, err)
+	rec, _ = serve(t, s, true, ScopeRead, http.Header{Header: {reader.Secret}})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestCreateKey_Validation(t *testing.T) {
+	ctx := context.Background()
+	s := NewService(newMemoryRepository(), knownTenants)
+
+	_, err := s.CreateKey(ctx, CreateKeyParams{Scopes: []Scope{ScopeRead}})
+	assert.ErrorIs(t, err, ErrInvalidName)
+	_, err = s.CreateKey(ctx, CreateKeyParams{Name: "ci"})
+	assert.ErrorIs(t, err, ErrInvalidScopes)
+	_, err = s.CreateKey(ctx, CreateKeyParams{Name: "ci", Scopes: []Scope{"write"}})
+	assert.ErrorIs(t, err, ErrInvalidScopes)
+	_, err = s.CreateKey(ctx, CreateKeyParams{Name: "ci", Scopes: []Scope{ScopeRead}, TenantID: "Acme"})
+	assert.ErrorIs(t, err, tenant.ErrInvalidTenant)
+	_, err = s.CreateKey(ctx, CreateKeyParams{Name: "ci", Scopes: []Scope{ScopeRead}, TenantID: "initech"})
+	assert.ErrorIs(t, err, tenant.ErrUnknownTenant)
+
+	acmeAdmin := WithKey(ctx, Key{ID: "acme-admin", Scopes: []Scope{ScopeAdmin}, TenantID: "acme"})
+	_, err = s.CreateKey(acmeAdmin, CreateKeyParams{Name: "ci", Scopes: []Scope{ScopeRead}, TenantID: "globex"})
+	assert.ErrorIs(t, err, ErrTenantDenied)
+	_, err = s.CreateKey(acmeAdmin, CreateKeyParams{Name: "ci", Scopes: []Scope{ScopeRead}})
+	assert.ErrorIs(t, err, ErrTenantDenied)
+	_, err = s.CreateKey(acmeAdmin, CreateKeyParams{Name: "ci", Scopes: []Scope{ScopeRead}, TenantID: "acme"})
+	assert.NoError(t, err)
+
+	k, err := s.CreateKey(ctx, CreateKeyParams{Name: "ci", Scopes: []Scope{ScopeSubmit, ScopeRead, ScopeRead}})
+	require.NoError(t, err)
+	assert.Equal(t, []Scope{ScopeRead, ScopeSubmit}, k.Scopes)
+	assert.Equal(t, k.Secret[:displayPrefix], k.Prefix)
+}
@@ -0,0 +1,167 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// As chaves são globais: é a chave que diz a qual tenant a requisição pertence.
+const (
+	keysKey       = "apikeys"
+	keyPrefix     = "apikeys:"
+	hashKeyPrefix = "apikeys:hash:"
+)
+
+type Repository interface {
+	SaveKey(ctx context.Context, k Key, hash string) error
+	FindKey(ctx context.Context, id string) (Key, error)
+	FindKeyByHash(ctx context.Context, hash string) (Key, error)
+	ListKeys(ctx context.Context) ([]Key, error)
+	RevokeKey(ctx context.Context, id string, at time.Time) (Key, error)
+}
+
+type repository struct {
+	rdb *database.Redis
+}
+
+func NewRepository(redis *database.Redis) Repository {
+	return &repository{
+		rdb: redis,
+	}
+}
+
+func (r *repository) SaveKey(ctx context.Context, k Key, hash string) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, keyPrefix+k.ID, keyHash(k, hash))
+		pipe.Set(ctx, hashKeyPrefix+hash, k.ID, 0)
+		pipe.SAdd(ctx, keysKey, k.ID)
+		return nil
+	})
+	return err
+}
+
+func (r *repository) FindKey(ctx context.Context, id string) (Key, error) {
+	v, err := r.rdb.HGetAll(ctx, keyPrefix+id).Result()
+	if err != nil {
+		return Key{}, err
+	}
+	if len(v) == 0 {
+		return Key{}, ErrKeyNotFound
+	}
+	return keyFromHash(v)
+}
+
+func (r *repository) FindKeyByHash(ctx context.Context, hash string) (Key, error) {
+	id, err := r.rdb.Get(ctx, hashKeyPrefix+hash).Result()
+	if errors.Is(err, redis.Nil) {
+		return Key{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	return r.FindKey(ctx, id)
+}
+
+func (r *repository) ListKeys(ctx context.Context) ([]Key, error) {
+	ids, err := r.rdb.SMembers(ctx, keysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, keyPrefix+id)
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]Key, 0, len(ids))
+	for _, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		k, err := keyFromHash(cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// RevokeKey marca a chave como revogada e apaga o índice pelo hash, então a
+// chave deixa de autenticar imediatamente mas continua na listagem.
+func (r *repository) RevokeKey(ctx context.Context, id string, at time.Time) (Key, error) {
+	hash, err := r.rdb.HGet(ctx, keyPrefix+id, "hash").Result()
+	if errors.Is(err, redis.Nil) {
+		return Key{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, keyPrefix+id, "revokedAt", at)
+		pipe.Del(ctx, hashKeyPrefix+hash)
+		return nil
+	})
+	if err != nil {
+		return Key{}, err
+	}
+	return r.FindKey(ctx, id)
+}
+
+func keyHash(k Key, hash string) map[string]any {
+	scopes := make([]string, len(k.Scopes))
+	for i, s := range k.Scopes {
+		scopes[i] = string(s)
+	}
+	body := map[string]any{
+		"id":        k.ID,
+		"name":      k.Name,
+		"prefix":    k.Prefix,
+		"scopes":    strings.Join(scopes, ","),
+		"tenantId":  k.TenantID,
+		"hash":      hash,
+		"createdAt": k.CreatedAt,
+		"revokedAt": "",
+	}
+	if !k.RevokedAt.IsZero() {
+		body["revokedAt"] = k.RevokedAt
+	}
+	return body
+}
+
+func keyFromHash(v map[string]string) (Key, error) {
+	k := Key{
+		ID:       v["id"],
+		Name:     v["name"],
+		Prefix:   v["prefix"],
+		TenantID: v["tenantId"],
+	}
+	for s := range strings.SplitSeq(v["scopes"], ",") {
+		if s != "" {
+			k.Scopes = append(k.Scopes, Scope(s))
+		}
+	}
+
+	var err error
+	if k.CreatedAt, err = time.Parse(time.RFC3339Nano, v["createdAt"]); err != nil {
+		return Key{}, err
+	}
+	if revoked := v["revokedAt"]; revoked != "" {
+		if k.RevokedAt, err = time.Parse(time.RFC3339Nano, revoked); err != nil {
+			return Key{}, err
+		}
+	}
+	return k, nil
+}
@@ -0,0 +1,149 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+)
+
+const (
+	secretPrefix  = "rk_"
+	displayPrefix = 10
+	maxNameLength = 100
+
+	// BootstrapKeyID é o id da chave admin criada a partir de AUTH_BOOTSTRAP_KEY.
+	BootstrapKeyID = "bootstrap"
+)
+
+type Service struct {
+	r     Repository
+	known func(id string) bool
+}
+
+// NewService recebe known para recusar chaves de tenants não cadastrados.
+func NewService(r Repository, known func(id string) bool) *Service {
+	return &Service{
+		r:     r,
+		known: known,
+	}
+}
+
+func (s *Service) CreateKey(ctx context.Context, params CreateKeyParams) (CreatedKey, error) {
+	if params.Name == "" || utf8.RuneCountInString(params.Name) > maxNameLength {
+		return CreatedKey{}, ErrInvalidName
+	}
+	if len(params.Scopes) == 0 {
+		return CreatedKey{}, ErrInvalidScopes
+	}
+	for _, scope := range params.Scopes {
+		if !scope.Valid() {
+			return CreatedKey{}, ErrInvalidScopes
+		}
+	}
+	if params.TenantID != "" {
+		if err := tenant.Validate(params.TenantID); err != nil {
+			return CreatedKey{}, err
+		}
+		if !s.known(params.TenantID) {
+			return CreatedKey{}, tenant.ErrUnknownTenant
+		}
+	}
+	// Quem está ligado a um tenant só cria chaves para ele mesmo.
+	if caller, ok := FromContext(ctx); ok && caller.TenantID != "" && caller.TenantID != params.TenantID {
+		return CreatedKey{}, ErrTenantDenied
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return CreatedKey{}, err
+	}
+	secret := secretPrefix + hex.EncodeToString(raw)
+
+	k := Key{
+		ID:        uuid.NewString(),
+		Name:      params.Name,
+		Prefix:    secret[:displayPrefix],
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(params.Scopes))),
+		TenantID:  params.TenantID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.r.SaveKey(ctx, k, Hash(secret)); err != nil {
+		return CreatedKey{}, err
+	}
+	return CreatedKey{Key: k, Secret: secret}, nil
+}
+
+// Bootstrap garante que o segredo configurado em AUTH_BOOTSTRAP_KEY autentica
+// como admin. Rodar de novo com outro segredo revoga o anterior.
+func (s *Service) Bootstrap(ctx context.Context, secret string) error {
+	if secret == "" {
+		return nil
+	}
+
+	hash := Hash(secret)
+	if k, err := s.r.FindKeyByHash(ctx, hash); err == nil && k.ID == BootstrapKeyID {
+		return nil
+	} else if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+
+	if _, err := s.r.RevokeKey(ctx, BootstrapKeyID, time.Now().UTC()); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+	return s.r.SaveKey(ctx, Key{
+		ID:        BootstrapKeyID,
+		Name:      "bootstrap",
+		Prefix:    secret[:min(displayPrefix, len(secret))],
+		Scopes:    []Scope{ScopeAdmin},
+		CreatedAt: time.Now().UTC(),
+	}, hash)
+}
+
+// Authenticate devolve a chave dona do segredo, se ela não foi revogada.
+func (s *Service) Authenticate(ctx context.Context, secret string) (Key, error) {
+	k, err := s.r.FindKeyByHash(ctx, Hash(secret))
+	if errors.Is(err, ErrKeyNotFound) {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	if k.Revoked() {
+		return Key{}, ErrInvalidKey
+	}
+	return k, nil
+}
+
+func (s *Service) FindKey(ctx context.Context, id string) (Key, error) {
+	return s.r.FindKey(ctx, id)
+}
+
+func (s *Service) ListKeys(ctx context.Context) ([]Key, error) {
+	keys, err := s.r.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(keys, func(a, b Key) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return keys, nil
+}
+
+func (s *Service) RevokeKey(ctx context.Context, id string) (Key, error) {
+	return s.r.RevokeKey(ctx, id, time.Now().UTC())
+}
+
+// Hash é o SHA-256 do segredo. As chaves têm 256 bits aleatórios, então não
+// precisam de um hash lento como senhas.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,92 @@
+package externalservices
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+)
+
+const (
+	TokenHeader     = "X-Rinha-Token"
+	SignatureHeader = "X-Signature"
+)
+
+// authTransport adiciona a autenticação configurada a toda chamada ao
+// processador: pagamentos, estornos, health check e endpoints /admin.
+type authTransport struct {
+	base http.RoundTripper
+	auth config.ProcessorAuth
+	now  func() time.Time
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.auth.Token != "" {
+		req.Header.Set(TokenHeader, t.auth.Token)
+	}
+	if t.auth.HMACSecret != "" {
+		var body []byte
+		if req.GetBody != nil {
+			rc, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			body, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		req.Header.Set(SignatureHeader, Sign(t.auth.HMACSecret, t.now(), hex.EncodeToString(nonce), req.Method, req.URL.RequestURI(), body))
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Sign gera o header X-Signature que enviamos ao processador, no formato
+// "t=<unix>,n=<nonce>,v1=<hex>", onde v1 é o HMAC-SHA256 de
+// "<unix>.<nonce>.<método> <path>.<body>". Cada requisição leva um timestamp
+// e um nonce novos, para que o processador possa recusar uma requisição
+// capturada e reenviada.
+func Sign(secret string, t time.Time, nonce, method, path string, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%s.%s.%s %s.", ts, nonce, method, path)
+	h.Write(body)
+	return fmt.Sprintf("t=%s,n=%s,v1=%s", ts, nonce, hex.EncodeToString(h.Sum(nil)))
+}
+
+func newClient(name ProcessorName, svc config.ExternalService) *http.Client {
+	auth := svc.Auth
+	var transport http.RoundTripper = http.DefaultTransport
+	if auth.ClientCert != "" || auth.CACert != "" {
+		// O config já recusa certificados que não carregam; se mesmo assim
+		// falhar, a instância não sobe em vez de chamar o processador sem o
+		// certificado do cliente.
+		tlsConfig, err := auth.TLSConfig()
+		if err != nil {
+			panic(fmt.Errorf("%s processor TLS: %w", name, err))
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsConfig
+		transport = t
+	}
+	if auth.Token != "" || auth.HMACSecret != "" {
+		transport = &authTransport{base: transport, auth: auth, now: time.Now}
+	}
+	return &http.Client{
+		Timeout:   svc.Timeout,
+		Transport: transport,
+	}
+}
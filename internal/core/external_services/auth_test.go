@@ -0,0 +1,70 @@
+package externalservices_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessorAuth(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"message":"payment processed successfully"}`))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{ExternalServices: config.ExternalServices{
+		DefaultPaymentProcessor: config.ExternalService{
+			BaseURL: srv.URL,
+			Auth:    config.ProcessorAuth{Token: "123", HMACSecret: "s3cr3t"},
+		},
+		FallbackPaymentProcessor: config.ExternalService{BaseURL: srv.URL},
+	}}
+	processors, _ := externalservices.NewRegistry(cfg).Processors("")
+
+	_, err := processors.Default.ProcessPayment(context.Background(), externalservices.PaymentParams{CorrelationID: "abc", Amount: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "123", header.Get(externalservices.TokenHeader))
+
+	parts := map[string]string{}
+	for part := range strings.SplitSeq(header.Get(externalservices.SignatureHeader), ",") {
+		k, v, _ := strings.Cut(part, "=")
+		parts[k] = v
+	}
+	unix, err := strconv.ParseInt(parts["t"], 10, 64)
+	assert.NoError(t, err)
+	assert.Len(t, parts["n"], 32)
+	assert.Equal(t,
+		externalservices.Sign("s3cr3t", time.Unix(unix, 0), parts["n"], http.MethodPost, "/payments", body),
+		header.Get(externalservices.SignatureHeader))
+
+	_, err = processors.Fallback.ProcessPayment(context.Background(), externalservices.PaymentParams{CorrelationID: "abc", Amount: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, header.Get(externalservices.TokenHeader))
+	assert.Empty(t, header.Get(externalservices.SignatureHeader))
+}
+
+func TestProcessorAuth_InvalidCertificate(t *testing.T) {
+	cfg := &config.Config{ExternalServices: config.ExternalServices{
+		DefaultPaymentProcessor: config.ExternalService{
+			BaseURL: "https://localhost:1",
+			Auth:    config.ProcessorAuth{ClientCert: "missing.pem", ClientKey: "missing-key.pem"},
+		},
+	}}
+	// O config recusa esses arquivos na validação; aqui eles chegam direto.
+	assert.PanicsWithError(t, "default processor TLS: open missing.pem: no such file or directory", func() {
+		externalservices.NewRegistry(cfg)
+	})
+}
@@ -4,18 +4,37 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"log/slog"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
 )
 
+var processorLog = logger.Component("processor")
+
 type PaymentProcessor interface {
 	ProcessorName() ProcessorName
 	ProcessPayment(ctx context.Context, params PaymentParams) (PaymentResponse, error)
 	VerifyHealth() (HealthCheckResponse, error)
+	FeeRate() float64
+	Admin
+}
+
+// Refunder é o ponto de extensão para estornos. Só os processadores que
+// implementam a interface aceitam POST /payments/{id}/refund; os da Rinha não
+// estornam, então o registry só a oferece com <prefix>REFUNDS=true.
+type Refunder interface {
+	RefundPayment(ctx context.Context, params RefundParams) (RefundResponse, error)
+}
+
+// Admin expõe os endpoints /admin do processador, que exigem o X-Rinha-Token.
+type Admin interface {
+	PaymentsSummary(ctx context.Context, from, to time.Time) (AdminSummaryResponse, error)
 }
 
 type ProcessorName string
@@ -28,6 +47,7 @@ const (
 type BasePaymentProcessorService struct {
 	Name    ProcessorName
 	BaseURL string
+	Fee     float64
 	Client  *http.Client
 }
 
@@ -35,8 +55,12 @@ func (b *BasePaymentProcessorService) ProcessorName() ProcessorName {
 	return b.Name
 }
 
+func (b *BasePaymentProcessorService) FeeRate() float64 {
+	return b.Fee
+}
+
 func (b *BasePaymentProcessorService) ProcessPayment(ctx context.Context, params PaymentParams) (PaymentResponse, error) {
-	slog.InfoContext(ctx, "processing payment with "+string(b.Name), "correlationID", params.CorrelationID)
+	processorLog.InfoContext(ctx, "processing payment with "+string(b.Name), "correlationID", params.CorrelationID)
 	url := strings.Join([]string{b.BaseURL, "/payments"}, "")
 
 	payload, err := json.Marshal(params)
@@ -56,13 +80,87 @@ func (b *BasePaymentProcessorService) ProcessPayment(ctx context.Context, params
 	}
 	defer resp.Body.Close()
 
-	slog.Info("process payment response", "response", resp.StatusCode)
+	processorLog.Info("process payment response", "response", resp.StatusCode)
 
 	var response PaymentResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return PaymentResponse{}, err
 	}
-	slog.InfoContext(ctx, "payment processed with "+string(b.Name), "correlationID", params.CorrelationID, "response", response)
+	processorLog.InfoContext(ctx, "payment processed with "+string(b.Name), "correlationID", params.CorrelationID, "response", response)
+	return response, nil
+}
+
+// refundPayment pede o estorno em POST /payments/{correlationId}/refunds.
+// Diferente de ProcessPayment, qualquer status fora de 2xx é erro: um estorno
+// não confirmado não pode ser contabilizado.
+func (b *BasePaymentProcessorService) refundPayment(ctx context.Context, params RefundParams) (RefundResponse, error) {
+	url := strings.Join([]string{b.BaseURL, "/payments/", params.CorrelationID, "/refunds"}, "")
+
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return RefundResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return RefundResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return RefundResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return RefundResponse{}, fmt.Errorf("refund rejected by %s processor: status %d: %s", b.Name, resp.StatusCode, msg)
+	}
+
+	var response RefundResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil && err != io.EOF {
+		return RefundResponse{}, err
+	}
+	processorLog.InfoContext(ctx, "payment refunded with "+string(b.Name), "correlationID", params.CorrelationID, "refundID", params.RefundID)
+	return response, nil
+}
+
+// PaymentsSummary consulta GET /admin/payments-summary, o total que o próprio
+// processador contabilizou no intervalo. Serve para conciliar com o nosso summary.
+func (b *BasePaymentProcessorService) PaymentsSummary(ctx context.Context, from, to time.Time) (AdminSummaryResponse, error) {
+	q := url.Values{}
+	if !from.IsZero() {
+		q.Set("from", from.UTC().Format(time.RFC3339Nano))
+	}
+	if !to.IsZero() {
+		q.Set("to", to.UTC().Format(time.RFC3339Nano))
+	}
+	u := b.BaseURL + "/admin/payments-summary"
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return AdminSummaryResponse{}, err
+	}
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return AdminSummaryResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return AdminSummaryResponse{}, fmt.Errorf("admin summary rejected by %s processor: status %d: %s", b.Name, resp.StatusCode, msg)
+	}
+
+	var response AdminSummaryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return AdminSummaryResponse{}, err
+	}
 	return response, nil
 }
 
@@ -94,27 +192,50 @@ type DefaultPaymentProcessor struct {
 
 func NewDefaultPaymentProcessor() *DefaultPaymentProcessor {
 	cfg := config.GetInstance()
+	return newDefaultPaymentProcessor(cfg.ExternalServices.DefaultPaymentProcessor)
+}
+
+func newDefaultPaymentProcessor(svc config.ExternalService) *DefaultPaymentProcessor {
 	return &DefaultPaymentProcessor{&BasePaymentProcessorService{
 		Name:    ProcessorDefault,
-		BaseURL: cfg.ExternalServices.DefaultPaymentProcessor.BaseURL,
-		Client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		BaseURL: svc.BaseURL,
+		Fee:     svc.FeeRate,
+		Client:  newClient(ProcessorDefault, svc),
 	}}
 }
 
+// refundingProcessor é o processador configurado com estorno.
+type refundingProcessor struct {
+	*BasePaymentProcessorService
+}
+
+func (p refundingProcessor) RefundPayment(ctx context.Context, params RefundParams) (RefundResponse, error) {
+	return p.refundPayment(ctx, params)
+}
+
+// withRefunds só expõe o Refunder quando svc.Refunds estiver ligado.
+func withRefunds(p *BasePaymentProcessorService, svc config.ExternalService) PaymentProcessor {
+	if svc.Refunds {
+		return refundingProcessor{p}
+	}
+	return p
+}
+
 type FallbackPaymentProcessor struct {
 	*BasePaymentProcessorService
 }
 
 func NewFallbackPaymentProcessor() *FallbackPaymentProcessor {
 	cfg := config.GetInstance()
+	return newFallbackPaymentProcessor(cfg.ExternalServices.FallbackPaymentProcessor)
+}
+
+func newFallbackPaymentProcessor(svc config.ExternalService) *FallbackPaymentProcessor {
 	return &FallbackPaymentProcessor{&BasePaymentProcessorService{
 		Name:    ProcessorFallback,
-		BaseURL: cfg.ExternalServices.FallbackPaymentProcessor.BaseURL,
-		Client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		BaseURL: svc.BaseURL,
+		Fee:     svc.FeeRate,
+		Client:  newClient(ProcessorFallback, svc),
 	}}
 }
 
@@ -0,0 +1,67 @@
+package externalservices
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+)
+
+// Processors é o par de processadores de um tenant.
+type Processors struct {
+	Default  PaymentProcessor
+	Fallback PaymentProcessor
+}
+
+func (p Processors) ByName(name ProcessorName) PaymentProcessor {
+	switch name {
+	case ProcessorDefault:
+		return p.Default
+	case ProcessorFallback:
+		return p.Fallback
+	default:
+		return nil
+	}
+}
+
+// Registry guarda os processadores de cada tenant. O tenant padrão ("") usa
+// a configuração global.
+type Registry struct {
+	tenants map[string]Processors
+}
+
+func NewRegistry(cfg *config.Config) *Registry {
+	r := &Registry{
+		tenants: map[string]Processors{
+			"": newProcessors(cfg.ExternalServices),
+		},
+	}
+	for _, t := range cfg.Tenants {
+		r.tenants[t.ID] = newProcessors(t.ExternalServices)
+	}
+	return r
+}
+
+func newProcessors(cfg config.ExternalServices) Processors {
+	def := newDefaultPaymentProcessor(cfg.DefaultPaymentProcessor)
+	fallback := newFallbackPaymentProcessor(cfg.FallbackPaymentProcessor)
+	return Processors{
+		Default:  withRefunds(def.BasePaymentProcessorService, cfg.DefaultPaymentProcessor),
+		Fallback: withRefunds(fallback.BasePaymentProcessorService, cfg.FallbackPaymentProcessor),
+	}
+}
+
+func (r *Registry) Processors(tenantID string) (Processors, bool) {
+	p, ok := r.tenants[tenantID]
+	return p, ok
+}
+
+func (r *Registry) Known(tenantID string) bool {
+	_, ok := r.tenants[tenantID]
+	return ok
+}
+
+// Tenants devolve os tenants cadastrados em ordem, começando pelo padrão.
+func (r *Registry) Tenants() []string {
+	return slices.Sorted(maps.Keys(r.tenants))
+}
@@ -0,0 +1,26 @@
+package externalservices_test
+
+import (
+	"testing"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Refunds(t *testing.T) {
+	cfg := &config.Config{ExternalServices: config.ExternalServices{
+		DefaultPaymentProcessor:  config.ExternalService{BaseURL: "http://pp-default", Refunds: true},
+		FallbackPaymentProcessor: config.ExternalService{BaseURL: "http://pp-fallback"},
+	}}
+	processors, ok := externalservices.NewRegistry(cfg).Processors("")
+	assert.True(t, ok)
+
+	_, ok = processors.Default.(externalservices.Refunder)
+	assert.True(t, ok)
+
+	// Sem <prefix>REFUNDS o processador é o da Rinha, que não estorna.
+	_, ok = processors.Fallback.(externalservices.Refunder)
+	assert.False(t, ok)
+	assert.Equal(t, externalservices.ProcessorFallback, processors.Fallback.ProcessorName())
+}
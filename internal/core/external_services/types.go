@@ -16,3 +16,21 @@ type HealthCheckResponse struct {
     MinResponseTime int `json:"minResponseTime"` // milliseconds
     Failing bool `json:"failing"`
 }
+
+type RefundParams struct {
+    CorrelationID string `json:"correlationId"`
+    RefundID      string `json:"refundId"`
+    Amount        float64 `json:"amount"`
+    RequestedAt   time.Time `json:"requestedAt"`
+}
+
+type RefundResponse struct {
+    Message string `json:"message"`
+}
+
+type AdminSummaryResponse struct {
+    TotalRequests int `json:"totalRequests"`
+    TotalAmount float64 `json:"totalAmount"`
+    TotalFee float64 `json:"totalFee"`
+    FeePerTransaction float64 `json:"feePerTransaction"`
+}
@@ -0,0 +1,55 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// livez só diz que o processo responde. Dependências ficam no readyz: reiniciar
+// a instância não resolve um Redis ou processador fora do ar.
+func (h *Handler) livez(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Report{Status: StatusOK})
+}
+
+func (h *Handler) readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.service.Ready(r.Context())
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// SetupRoutes registra /livez e /readyz sem exigir API key: quem consulta é o
+// balanceador e o orquestrador.
+func SetupRoutes(r *chi.Mux, db *database.Redis, worker *payment.PaymentWorker) {
+	cfg := config.GetInstance()
+	service := NewService(map[string]Checker{
+		"redis":      RedisCheck(db),
+		"queue":      QueueCheck(payment.GetQueueStats),
+		"workers":    WorkersCheck(worker, cfg.Payment.Timeout+15*time.Second),
+		"processors": ProcessorsCheck(payment.NewService(payment.NewRepository(db))),
+	})
+	handler := NewHandler(service)
+	r.Get("/livez", handler.livez)
+	r.Get("/readyz", handler.readyz)
+}
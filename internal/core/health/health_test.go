@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func static(c Check) Checker {
+	return func(context.Context) Check { return c }
+}
+
+func TestReadyz(t *testing.T) {
+	ok := static(Check{Status: StatusOK})
+	down := static(fail("boom", nil))
+
+	tests := []struct {
+		name   string
+		checks map[string]Checker
+		status int
+	}{
+		{"all ok", map[string]Checker{"redis": ok, "queue": ok}, http.StatusOK},
+		{"one failing", map[string]Checker{"redis": down, "queue": ok}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewHandler(NewService(tt.checks)).readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.status, rec.Code)
+
+			var report Report
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			assert.Len(t, report.Checks, len(tt.checks))
+			assert.Equal(t, tt.status == http.StatusOK, report.Status == StatusOK)
+		})
+	}
+}
+
+func TestQueueCheck(t *testing.T) {
+	c := QueueCheck(func() payment.QueueStats { return payment.QueueStats{Size: 10, Capacity: 100} })(context.Background())
+	assert.Equal(t, StatusOK, c.Status)
+
+	c = QueueCheck(func() payment.QueueStats { return payment.QueueStats{Size: 95, Capacity: 100} })(context.Background())
+	assert.Equal(t, StatusFail, c.Status)
+	assert.Equal(t, 95, c.Details["size"])
+}
+
+func TestWorkersCheck(t *testing.T) {
+	assert.Equal(t, StatusFail, WorkersCheck(nil, time.Minute)(context.Background()).Status)
+
+	// Workers que nunca rodaram não contam como vivos.
+	c := WorkersCheck(payment.NewPaymentWorker(nil, config.Payment{Workers: 2}), time.Minute)(context.Background())
+	assert.Equal(t, StatusFail, c.Status)
+	assert.Equal(t, 2, c.Details["total"])
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+)
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+const (
+	checkTimeout = 2 * time.Second
+	// A fila acima disso já devolve 503 no POST /payments em pouco tempo;
+	// melhor o balanceador mandar o tráfego para a outra instância antes.
+	queueThreshold = 0.9
+)
+
+type Check struct {
+	Status  Status         `json:"status"`
+	Error   string         `json:"error,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status Status           `json:"status"`
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+type Checker func(ctx context.Context) Check
+
+type Service struct {
+	checks map[string]Checker
+}
+
+func NewService(checks map[string]Checker) *Service {
+	return &Service{
+		checks: checks,
+	}
+}
+
+// Ready roda todas as verificações em paralelo. A instância só está pronta se
+// todas passarem.
+func (s *Service) Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Check, len(s.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := check(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = c
+			if c.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func fail(err string, details map[string]any) Check {
+	return Check{Status: StatusFail, Error: err, Details: details}
+}
+
+func RedisCheck(db *database.Redis) Checker {
+	return func(ctx context.Context) Check {
+		start := time.Now()
+		if err := db.Ping(ctx).Err(); err != nil {
+			return fail(err.Error(), nil)
+		}
+		return Check{Status: StatusOK, Details: map[string]any{
+			"latencyMs": time.Since(start).Milliseconds(),
+		}}
+	}
+}
+
+func QueueCheck(stats func() payment.QueueStats) Checker {
+	return func(context.Context) Check {
+		q := stats()
+		usage := float64(q.Size) / float64(q.Capacity)
+		details := map[string]any{
+			"size":          q.Size,
+			"capacity":      q.Capacity,
+			"usage":         usage,
+			"errorSize":     q.ErrorSize,
+			"errorCapacity": q.ErrorCapacity,
+		}
+		if usage >= queueThreshold {
+			return fail("payment queue is saturated", details)
+		}
+		return Check{Status: StatusOK, Details: details}
+	}
+}
+
+// WorkersCheck considera morto o worker sem heartbeat há mais de staleAfter,
+// que precisa ser maior que o timeout de um pagamento.
+func WorkersCheck(w *payment.PaymentWorker, staleAfter time.Duration) Checker {
+	return func(context.Context) Check {
+		if w == nil {
+			return fail("payment worker is not running", nil)
+		}
+		alive, total := w.AliveWorkers(staleAfter)
+		details := map[string]any{
+			"alive": alive,
+			"total": total,
+		}
+		if alive == 0 {
+			return fail("no payment worker is alive", details)
+		}
+		return Check{Status: StatusOK, Details: details}
+	}
+}
+
+// ProcessorsCheck lê o último health check salvo de cada processador, de todos
+// os tenants. Basta um processador utilizável para a instância estar pronta:
+// sem nenhum, os pagamentos só se acumulam na fila.
+func ProcessorsCheck(s *payment.Service) Checker {
+	return func(ctx context.Context) Check {
+		usable := 0
+		tenants := make(map[string]any)
+		for _, id := range s.Tenants() {
+			tenantCtx := tenant.WithTenant(ctx, id)
+			processors := make(map[string]any, 2)
+			for _, name := range []externalservices.ProcessorName{externalservices.ProcessorDefault, externalservices.ProcessorFallback} {
+				h, err := s.FindProcessorHealth(tenantCtx, name)
+				if err != nil {
+					processors[string(name)] = map[string]any{"usable": false, "error": err.Error()}
+					continue
+				}
+				if !h.Failing {
+					usable++
+				}
+				processors[string(name)] = map[string]any{
+					"usable":          !h.Failing,
+					"minResponseTime": h.MinResponseTime,
+				}
+			}
+			tenants[id] = processors
+		}
+		details := map[string]any{"tenants": tenants}
+		if usable == 0 {
+			return fail("no payment processor is usable", details)
+		}
+		return Check{Status: StatusOK, Details: details}
+	}
+}
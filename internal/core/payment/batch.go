@@ -0,0 +1,158 @@
+package payment
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+)
+
+const (
+	maxBatchItems = 10000
+	batchChunk    = 500
+	// maxBatchLine limita cada linha NDJSON; um pagamento ocupa bem menos.
+	maxBatchLine = 64 * 1024
+)
+
+var ErrBatchTooLarge = errors.New("batch exceeds the maximum number of items")
+
+// BatchItem é um item ainda não validado do lote; Err guarda o erro de decode.
+type BatchItem struct {
+	Params PaymentParams
+	Err    error
+}
+
+// DecodeBatchArray lê um array JSON sem abortar o lote inteiro quando um item
+// é inválido.
+func DecodeBatchArray(r io.Reader, fn func(BatchItem) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return errors.New("expected a JSON array")
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		var item BatchItem
+		item.Err = json.Unmarshal(raw, &item.Params)
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeBatchNDJSON lê um pagamento por linha; linhas em branco são ignoradas.
+func DecodeBatchNDJSON(r io.Reader, fn func(BatchItem) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), maxBatchLine)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var item BatchItem
+		item.Err = json.Unmarshal(line, &item.Params)
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// ProcessPaymentBatch valida o lote inteiro e só então grava e enfileira os
+// pagamentos em blocos de batchChunk, usando uma ida ao Redis por bloco. Um
+// lote acima de maxBatchItems é recusado sem gravar nenhum item.
+func (s *Service) ProcessPaymentBatch(ctx context.Context, clientID string, decode func(fn func(BatchItem) error) error) (BatchResult, error) {
+	result := BatchResult{Results: []BatchItemResult{}}
+	seen := make(map[string]struct{})
+
+	var payments []Payment
+	var indexes []int
+	index := 0
+
+	err := decode(func(item BatchItem) error {
+		i := index
+		index++
+		if index > maxBatchItems {
+			return ErrBatchTooLarge
+		}
+
+		item.Params.ClientID = clientID
+		if item.Err == nil {
+			item.Err = item.Params.Validate()
+		}
+		if item.Err != nil {
+			result.add(BatchItemResult{Index: i, CorrelationID: item.Params.CorrelationID, Status: BatchItemInvalid, Error: item.Err.Error()})
+			return nil
+		}
+
+		if _, dup := seen[item.Params.CorrelationID]; dup {
+			result.add(BatchItemResult{Index: i, CorrelationID: item.Params.CorrelationID, Status: BatchItemDuplicate})
+			return nil
+		}
+		seen[item.Params.CorrelationID] = struct{}{}
+
+		p := item.Params.toPayment()
+		p.TenantID = tenant.FromContext(ctx)
+		payments = append(payments, p)
+		indexes = append(indexes, i)
+		return nil
+	})
+	if errors.Is(err, ErrBatchTooLarge) {
+		return BatchResult{Results: []BatchItemResult{}, Error: err.Error()}, err
+	}
+
+	// Um erro no meio do stream não desfaz o que já foi aceito: os itens lidos
+	// até ali são gravados e o erro vai junto com os resultados.
+	for start := 0; start < len(payments); start += batchChunk {
+		end := min(start+batchChunk, len(payments))
+		if storeErr := s.storeBatchChunk(ctx, payments[start:end], indexes[start:end], &result); storeErr != nil {
+			return result, storeErr
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	// Os resultados saem na ordem do lote, não na ordem em que foram resolvidos.
+	slices.SortFunc(result.Results, func(a, b BatchItemResult) int { return a.Index - b.Index })
+	return result, err
+}
+
+func (s *Service) storeBatchChunk(ctx context.Context, chunk []Payment, indexes []int, result *BatchResult) error {
+	created, err := s.r.CreatePayments(ctx, chunk)
+	if err != nil {
+		return err
+	}
+	for i, p := range chunk {
+		item := BatchItemResult{Index: indexes[i], CorrelationID: p.CorrelationID}
+		switch {
+		case !created[i]:
+			item.Status = BatchItemDuplicate
+		case p.Status == PaymentStatusScheduled:
+			item.Status = BatchItemAccepted
+		case !SendToQueue(p):
+			item.Status = BatchItemRejected
+			item.Error = "payment queue is full"
+			p.Status = PaymentStatusDead
+			if err := s.r.SavePayment(context.WithoutCancel(ctx), p); err != nil {
+				serviceLog.Error("failed to update payment status", "error", err, "correlation_id", p.CorrelationID)
+			}
+		default:
+			item.Status = BatchItemAccepted
+		}
+		result.add(item)
+	}
+	return nil
+}
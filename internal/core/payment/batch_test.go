@@ -0,0 +1,96 @@
+package payment
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeBatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func(fn func(BatchItem) error) error
+	}{
+		{"array", func(fn func(BatchItem) error) error {
+			return DecodeBatchArray(strings.NewReader(`[
+				{"correlationId": "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", "amount": 19.9},
+				{"correlationId": 42, "amount": 1},
+				{"correlationId": "not-a-uuid", "amount": 1}
+			]`), fn)
+		}},
+		{"ndjson", func(fn func(BatchItem) error) error {
+			return DecodeBatchNDJSON(strings.NewReader(
+				`{"correlationId": "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", "amount": 19.9}`+"\n"+
+					`{"correlationId": 42, "amount": 1}`+"\n\n"+
+					`{"correlationId": "not-a-uuid", "amount": 1}`+"\n"), fn)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []BatchItem
+			err := tt.decode(func(item BatchItem) error {
+				items = append(items, item)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Len(t, items, 3)
+
+			assert.NoError(t, items[0].Err)
+			assert.NoError(t, items[0].Params.Validate())
+			assert.Error(t, items[1].Err)
+			assert.NoError(t, items[2].Err)
+			assert.ErrorIs(t, items[2].Params.Validate(), ErrInvalidCorrelationID)
+		})
+	}
+}
+
+func TestDecodeBatchArray_NotAnArray(t *testing.T) {
+	err := DecodeBatchArray(strings.NewReader(`{"correlationId": "x"}`), func(BatchItem) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestPaymentParamsValidate(t *testing.T) {
+	id := "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+
+	assert.NoError(t, PaymentParams{CorrelationID: id, Amount: 0.01}.Validate())
+	assert.ErrorIs(t, PaymentParams{CorrelationID: id, Amount: 0}.Validate(), ErrInvalidAmount)
+	assert.ErrorIs(t, PaymentParams{CorrelationID: id, Amount: 0.001}.Validate(), ErrInvalidAmount)
+	assert.ErrorIs(t, PaymentParams{CorrelationID: "", Amount: 1}.Validate(), ErrInvalidCorrelationID)
+	assert.ErrorIs(t, PaymentParams{CorrelationID: "tenant:acme:" + id, Amount: 1}.Validate(), ErrInvalidCorrelationID)
+	assert.ErrorIs(t, PaymentParams{CorrelationID: "urn:uuid:" + id, Amount: 1}.Validate(), ErrInvalidCorrelationID)
+}
+
+func TestPaymentParamsValidate_Attributes(t *testing.T) {
+	base := PaymentParams{CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", Amount: 1}
+
+	valid := base
+	valid.MerchantID = "store-42"
+	valid.Currency = "BRL"
+	valid.Description = "pedido #1234"
+	valid.Metadata = map[string]string{"order.id": "1234"}
+	assert.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name string
+		edit func(p *PaymentParams)
+		want error
+	}{
+		{"merchant with spaces", func(p *PaymentParams) { p.MerchantID = "store 42" }, ErrInvalidMerchantID},
+		{"merchant too long", func(p *PaymentParams) { p.MerchantID = strings.Repeat("m", 65) }, ErrInvalidMerchantID},
+		{"lowercase currency", func(p *PaymentParams) { p.Currency = "brl" }, ErrInvalidCurrency},
+		{"description too long", func(p *PaymentParams) { p.Description = strings.Repeat("d", 256) }, ErrInvalidDescription},
+		{"empty metadata key", func(p *PaymentParams) { p.Metadata = map[string]string{"": "x"} }, ErrInvalidMetadata},
+		{"metadata value too long", func(p *PaymentParams) { p.Metadata = map[string]string{"k": strings.Repeat("v", 501)} }, ErrInvalidMetadata},
+		{"callback to cloud metadata", func(p *PaymentParams) { p.CallbackURL = "http://169.254.169.254/latest" }, ErrInvalidCallbackURL},
+		{"callback to private network", func(p *PaymentParams) { p.CallbackURL = "http://10.0.0.7:6379" }, ErrInvalidCallbackURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := base
+			tt.edit(&p)
+			assert.ErrorIs(t, p.Validate(), tt.want)
+		})
+	}
+}
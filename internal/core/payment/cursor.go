@@ -0,0 +1,41 @@
+package payment
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// listCursor é a posição do último item devolvido pela listagem. Para o
+// cliente ele é opaco: score e correlationId codificados em base64.
+type listCursor struct {
+	Score float64
+	ID    string
+}
+
+// precedes informa se o cursor vem antes de (score, id) na ordenação do Redis,
+// que desempata membros com o mesmo score pela ordem lexicográfica do membro.
+func (c listCursor) precedes(score float64, id string) bool {
+	return score > c.Score || (score == c.Score && id > c.ID)
+}
+
+func encodeListCursor(c listCursor) string {
+	raw := strconv.FormatFloat(c.Score, 'f', -1, 64) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeListCursor(v string) (listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return listCursor{}, ErrInvalidCursor
+	}
+	score, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return listCursor{}, ErrInvalidCursor
+	}
+	f, err := strconv.ParseFloat(score, 64)
+	if err != nil {
+		return listCursor{}, ErrInvalidCursor
+	}
+	return listCursor{Score: f, ID: id}, nil
+}
@@ -0,0 +1,25 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListCursor(t *testing.T) {
+	c := listCursor{Score: 1.7520768e+18, ID: "0197f0c2-aaaa"}
+
+	decoded, err := decodeListCursor(encodeListCursor(c))
+	assert.NoError(t, err)
+	assert.Equal(t, c, decoded)
+
+	assert.True(t, c.precedes(c.Score+1024, "0"))
+	assert.True(t, c.precedes(c.Score, "0197f0c2-bbbb"))
+	assert.False(t, c.precedes(c.Score, c.ID))
+	assert.False(t, c.precedes(c.Score-1024, "zzz"))
+
+	for _, invalid := range []string{"not base64!", "bm9waXBl", "YWJjfA"} {
+		_, err := decodeListCursor(invalid)
+		assert.ErrorIs(t, err, ErrInvalidCursor, invalid)
+	}
+}
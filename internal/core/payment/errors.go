@@ -1,6 +1,53 @@
 package payment
 
-import "errors"
+import (
+	"errors"
+	"net/http"
 
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
 
 var ErrAllProcessorsAreDown = errors.New("all payment processors are down; try again later")
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var (
+	ErrInvalidCorrelationID = errors.New("correlationId must be a valid UUID")
+	ErrInvalidAmount        = errors.New("amount must be greater than zero")
+	ErrInvalidCallbackURL   = errors.New("callbackUrl must be an absolute http(s) URL to a public host")
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+var (
+	ErrPaymentNotCancellable = errors.New("only pending or scheduled payments not yet sent to a processor can be cancelled")
+	ErrPaymentNotRefundable  = errors.New("only successful payments can be refunded")
+	ErrRefundExceedsAmount   = errors.New("refund amount exceeds the refundable balance")
+	ErrRefundNotSupported    = errors.New("the payment processor does not support refunds")
+	ErrRefundFailed          = errors.New("the payment processor rejected the refund")
+)
+
+var (
+	ErrInvalidMerchantID  = errors.New("merchantId must have up to 64 letters, digits, '-', '_' or '.'")
+	ErrInvalidDescription = errors.New("description must have up to 255 characters")
+	ErrInvalidCurrency    = errors.New("currency must be an ISO 4217 code such as BRL")
+	ErrInvalidMetadata    = errors.New("metadata accepts up to 20 keys of up to 40 letters, digits, '-', '_' or '.', with values of up to 500 characters")
+)
+
+func init() {
+	xerror.Register(ErrAllProcessorsAreDown, xerror.Mapping{Status: http.StatusServiceUnavailable, Code: "processors_unavailable"})
+	xerror.Register(ErrInvalidCursor, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_cursor", Field: "cursor"})
+	xerror.Register(ErrInvalidCorrelationID, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_correlation_id", Field: "correlationId"})
+	xerror.Register(ErrInvalidAmount, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_amount", Field: "amount"})
+	xerror.Register(ErrInvalidCallbackURL, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_callback_url", Field: "callbackUrl"})
+	xerror.Register(ErrPaymentNotFound, xerror.Mapping{Status: http.StatusNotFound, Code: "payment_not_found"})
+	xerror.Register(ErrPaymentNotCancellable, xerror.Mapping{Status: http.StatusConflict, Code: "payment_not_cancellable"})
+	xerror.Register(ErrPaymentNotRefundable, xerror.Mapping{Status: http.StatusConflict, Code: "payment_not_refundable"})
+	xerror.Register(ErrRefundExceedsAmount, xerror.Mapping{Status: http.StatusUnprocessableEntity, Code: "refund_exceeds_amount", Field: "amount"})
+	xerror.Register(ErrRefundNotSupported, xerror.Mapping{Status: http.StatusNotImplemented, Code: "refund_not_supported"})
+	xerror.Register(ErrRefundFailed, xerror.Mapping{Status: http.StatusBadGateway, Code: "refund_failed"})
+	xerror.Register(ErrInvalidMerchantID, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_merchant_id", Field: "merchantId"})
+	xerror.Register(ErrInvalidDescription, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_description", Field: "description"})
+	xerror.Register(ErrInvalidCurrency, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_currency", Field: "currency"})
+	xerror.Register(ErrInvalidMetadata, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_metadata", Field: "metadata"})
+}
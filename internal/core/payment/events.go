@@ -0,0 +1,233 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// EventsStream recebe um registro a cada mudança de status. É a fonte dos
+	// webhooks de conclusão e do replay do stream de eventos.
+	EventsStream = "payments:events"
+	// EventsChannel recebe as mesmas mudanças via pub/sub, com o id do stream,
+	// para que todas as instâncias repassem os eventos em tempo real.
+	EventsChannel      = "payments:events"
+	eventsStreamMaxLen = 100000
+	eventPayloadField  = "payment"
+
+	subscriberBuffer = 256
+	replayPageSize   = 500
+	// heartbeatInterval mantém a conexão SSE viva através do nginx.
+	heartbeatInterval = 15 * time.Second
+)
+
+var ErrInvalidEvent = errors.New("invalid payment event")
+
+// publishEvent grava a mudança no stream e publica "<id>\n<payload>" no canal,
+// de forma atômica, para que o evento ao vivo e o replay tenham o mesmo id.
+// Roda antes do HSET: se o hash KEYS[3] já tem o status ARGV[3], o save não
+// mudou nada e não há evento, a menos que ARGV[4] marque o pagamento como
+// recém-criado.
+var publishEvent = redis.NewScript(`
+if ARGV[4] ~= '1' and redis.call('HGET', KEYS[3], 'status') == ARGV[3] then return false end
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[1], '*', 'payment', ARGV[2])
+redis.call('PUBLISH', KEYS[2], id .. '\n' .. ARGV[2])
+return id
+`)
+
+// StatusEvent é uma mudança de status lida do EventsStream. ID é o id do
+// registro no stream, que cresce monotonicamente.
+type StatusEvent struct {
+	ID      string  `json:"id"`
+	Payment Payment `json:"payment"`
+}
+
+func DecodeStatusEvent(msg redis.XMessage) (StatusEvent, error) {
+	raw, ok := msg.Values[eventPayloadField].(string)
+	if !ok {
+		return StatusEvent{}, ErrInvalidEvent
+	}
+	return decodeStatusEvent(msg.ID, raw)
+}
+
+func decodePublishedEvent(msg string) (StatusEvent, error) {
+	id, raw, ok := strings.Cut(msg, "\n")
+	if !ok {
+		return StatusEvent{}, ErrInvalidEvent
+	}
+	return decodeStatusEvent(id, raw)
+}
+
+func decodeStatusEvent(id, raw string) (StatusEvent, error) {
+	payments := decodeMembers([]string{raw})
+	if len(payments) == 0 {
+		return StatusEvent{}, ErrInvalidEvent
+	}
+	return StatusEvent{ID: id, Payment: payments[0]}, nil
+}
+
+// streamIDAfter informa se o id a vem depois de b no stream ("<ms>-<seq>").
+func streamIDAfter(a, b string) bool {
+	aMs, aSeq := splitStreamID(a)
+	bMs, bSeq := splitStreamID(b)
+	return aMs > bMs || (aMs == bMs && aSeq > bSeq)
+}
+
+func splitStreamID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	m, _ := strconv.ParseUint(ms, 10, 64)
+	s, _ := strconv.ParseUint(seq, 10, 64)
+	return m, s
+}
+
+// EventFilter seleciona os eventos de um cliente SSE. O stream é compartilhado
+// entre os tenants, então o TenantID sempre é comparado, mesmo vazio;
+// Processors e Statuses vazios aceitam qualquer valor.
+type EventFilter struct {
+	TenantID   string
+	Processors []string
+	Statuses   []PaymentStatus
+}
+
+func (f EventFilter) Match(p Payment) bool {
+	if p.TenantID != f.TenantID {
+		return false
+	}
+	if len(f.Processors) > 0 && !slices.Contains(f.Processors, p.Processor) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, p.Status) {
+		return false
+	}
+	return true
+}
+
+// EventHub mantém uma única assinatura do EventsChannel por instância e
+// repassa cada evento para os clientes conectados nela.
+type EventHub struct {
+	rdb *database.Redis
+
+	mu          sync.Mutex
+	subscribers map[chan StatusEvent]struct{}
+	closed      bool
+}
+
+func NewEventHub(db *database.Redis) *EventHub {
+	return &EventHub{
+		rdb:         db,
+		subscribers: make(map[chan StatusEvent]struct{}),
+	}
+}
+
+func (h *EventHub) Run(ctx context.Context) {
+	pubsub := h.rdb.Subscribe(ctx, EventsChannel)
+	defer pubsub.Close()
+
+	serviceLog.Info("Starting payment event hub")
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			h.Close()
+			serviceLog.Info("Payment event hub stopped")
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				h.Close()
+				return
+			}
+			event, err := decodePublishedEvent(msg.Payload)
+			if err != nil {
+				serviceLog.Warn("discarding invalid payment event", "error", err)
+				continue
+			}
+			h.broadcast(event)
+		}
+	}
+}
+
+func (h *EventHub) broadcast(event StatusEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		select {
+		case sub <- event:
+		default:
+			// Cliente lento: a conexão é encerrada e ele retoma pelo Last-Event-ID.
+			delete(h.subscribers, sub)
+			close(sub)
+		}
+	}
+}
+
+// Subscribe registra um cliente. O canal é fechado quando o cliente fica para
+// trás, quando o hub é encerrado ou quando unsubscribe é chamado.
+func (h *EventHub) Subscribe() (<-chan StatusEvent, func()) {
+	sub := make(chan StatusEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(sub)
+		return sub, func() {}
+	}
+	h.subscribers[sub] = struct{}{}
+
+	return sub, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[sub]; ok {
+			delete(h.subscribers, sub)
+			close(sub)
+		}
+	}
+}
+
+// Close encerra todos os streams abertos; usado no shutdown do servidor, que
+// não cancela o contexto das requisições em andamento.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub)
+	}
+}
+
+// Replay lê do EventsStream os eventos posteriores a lastID, em páginas.
+func (h *EventHub) Replay(ctx context.Context, lastID string, fn func(StatusEvent) error) (string, error) {
+	start := "(" + lastID
+	for {
+		msgs, err := h.rdb.XRangeN(ctx, EventsStream, start, "+", replayPageSize).Result()
+		if err != nil {
+			return lastID, err
+		}
+		for _, msg := range msgs {
+			lastID = msg.ID
+			event, err := DecodeStatusEvent(msg)
+			if err != nil {
+				continue
+			}
+			if err := fn(event); err != nil {
+				return lastID, err
+			}
+		}
+		if len(msgs) < replayPageSize {
+			return lastID, nil
+		}
+		start = "(" + lastID
+	}
+}
@@ -0,0 +1,66 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamIDAfter(t *testing.T) {
+	assert.True(t, streamIDAfter("1752148800001-0", "1752148800000-5"))
+	assert.True(t, streamIDAfter("1752148800000-10", "1752148800000-9"))
+	assert.False(t, streamIDAfter("1752148800000-9", "1752148800000-9"))
+	assert.False(t, streamIDAfter("1752148799999-99", "1752148800000-0"))
+}
+
+func TestDecodePublishedEvent(t *testing.T) {
+	event, err := decodePublishedEvent("1752148800000-0\n" + `{"correlationId":"a","amount":1990,"processor":"default","status":"success"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1752148800000-0", event.ID)
+	assert.Equal(t, "a", event.Payment.CorrelationID)
+	assert.InDelta(t, 19.90, event.Payment.Amount, 0.001)
+
+	_, err = decodePublishedEvent("no-separator")
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+}
+
+func TestEventFilter(t *testing.T) {
+	p := Payment{Processor: "default", Status: PaymentStatusSuccess}
+
+	assert.True(t, EventFilter{}.Match(p))
+	assert.True(t, EventFilter{Processors: []string{"fallback", "default"}}.Match(p))
+	assert.False(t, EventFilter{Processors: []string{"fallback"}}.Match(p))
+	assert.False(t, EventFilter{Statuses: []PaymentStatus{PaymentStatusFailed}}.Match(p))
+	assert.False(t, EventFilter{TenantID: "acme"}.Match(p))
+
+	p.TenantID = "acme"
+	assert.True(t, EventFilter{TenantID: "acme"}.Match(p))
+	assert.False(t, EventFilter{}.Match(p))
+}
+
+func TestEventHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewEventHub(nil)
+	fast, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+	slow, _ := hub.Subscribe()
+
+	for range subscriberBuffer {
+		hub.broadcast(StatusEvent{ID: "1-0"})
+	}
+	// Drena o rápido para que só o lento fique sem espaço.
+	for range subscriberBuffer {
+		<-fast
+	}
+	hub.broadcast(StatusEvent{ID: "2-0"})
+
+	assert.Equal(t, "2-0", (<-fast).ID)
+	for range subscriberBuffer {
+		<-slow
+	}
+	_, open := <-slow
+	assert.False(t, open)
+
+	hub.Close()
+	_, open = <-fast
+	assert.False(t, open)
+}
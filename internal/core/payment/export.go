@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+func ParseExportFormat(v string) (ExportFormat, error) {
+	switch ExportFormat(v) {
+	case "", ExportCSV:
+		return ExportCSV, nil
+	case ExportNDJSON:
+		return ExportNDJSON, nil
+	default:
+		return "", fmt.Errorf("invalid export format %q", v)
+	}
+}
+
+func (f ExportFormat) ContentType() string {
+	if f == ExportNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+var exportCSVHeader = []string{"correlationId", "amount", "processor", "status", "startedAt", "requestedAt", "latencyMs", "refundedAmount", "merchantId", "currency"}
+
+// ExportPayments escreve em out todos os pagamentos da janela, página por
+// página. Se out for um http.Flusher, cada página é enviada ao cliente assim
+// que escrita.
+func (s *Service) ExportPayments(ctx context.Context, params PaymentSummaryParams, format ExportFormat, out io.Writer) error {
+	flush := func() {
+		if f, ok := out.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	switch format {
+	case ExportNDJSON:
+		enc := json.NewEncoder(out)
+		return s.r.ScanPayments(ctx, params, func(page []Payment) error {
+			for _, p := range page {
+				if err := enc.Encode(p); err != nil {
+					return err
+				}
+			}
+			flush()
+			return nil
+		})
+	default:
+		cw := csv.NewWriter(out)
+		if err := cw.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		err := s.r.ScanPayments(ctx, params, func(page []Payment) error {
+			for _, p := range page {
+				if err := cw.Write(csvRecord(p)); err != nil {
+					return err
+				}
+			}
+			cw.Flush()
+			flush()
+			return cw.Error()
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+func csvRecord(p Payment) []string {
+	var requestedAt string
+	if !p.RequestedAt.IsZero() {
+		requestedAt = p.RequestedAt.Format(time.RFC3339Nano)
+	}
+	return []string{
+		p.CorrelationID,
+		strconv.FormatFloat(p.Amount, 'f', 2, 64),
+		p.Processor,
+		string(p.Status),
+		p.StartedAt.Format(time.RFC3339Nano),
+		requestedAt,
+		strconv.FormatInt(p.LatencyMs, 10),
+		strconv.FormatFloat(p.RefundedAmount, 'f', 2, 64),
+		p.MerchantID,
+		p.Currency,
+	}
+}
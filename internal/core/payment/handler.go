@@ -2,31 +2,56 @@ package payment
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/apikey"
 	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
 	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
 	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
 )
 
+// ClientIDHeader identifica o cliente que enviou o pagamento.
+const ClientIDHeader = "X-Client-Id"
+
+const (
+	maxTimeSeriesBuckets = 10000
+
+	defaultListLimit = 50
+	maxListLimit     = 500
+
+	maxBatchBodyBytes = 16 << 20
+)
+
+var timeSeriesSteps = map[string]time.Duration{
+	"1s": time.Second,
+	"1m": time.Minute,
+	"1h": time.Hour,
+}
+
 type Handler struct {
 	service *Service
+	events  *EventHub
 }
 
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, events *EventHub) *Handler {
 	return &Handler{
 		service: service,
+		events:  events,
 	}
 }
 
 func (h *Handler) getHealthStatus(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
 	if name == "" {
-		xerr := xerror.NewCustomError(http.StatusBadRequest, "missing processor name", nil)
-		w.WriteHeader(xerr.Code)
-		json.NewEncoder(w).Encode(xerr)
+		xerror.Write(w, r, xerror.NewValidationError("name", "missing processor name", nil))
 		return
 	}
 
@@ -34,16 +59,12 @@ func (h *Handler) getHealthStatus(w http.ResponseWriter, r *http.Request) {
 
 	switch processorName {
 	default:
-		xerr := xerror.NewCustomError(http.StatusBadRequest, "invalid processor name", nil)
-		w.WriteHeader(xerr.Code)
-		json.NewEncoder(w).Encode(xerr)
+		xerror.Write(w, r, xerror.NewValidationError("name", "invalid processor name", nil))
 		return
 	case externalservices.ProcessorDefault, externalservices.ProcessorFallback:
 		h, err := h.service.GetHealthStatus(r.Context(), processorName)
 		if err != nil {
-			xerr := xerror.NewCustomError(http.StatusInternalServerError, "internal error", err)
-			w.WriteHeader(xerr.Code)
-			json.NewEncoder(w).Encode(xerr)
+			xerror.Write(w, r, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -52,67 +73,325 @@ func (h *Handler) getHealthStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getProcessorSummary devolve o summary do /admin/payments-summary do
+// processador, para conciliar com o /payments-summary.
+func (h *Handler) getProcessorSummary(w http.ResponseWriter, r *http.Request) {
+	name := externalservices.ProcessorName(r.URL.Query().Get("name"))
+	if name != externalservices.ProcessorDefault && name != externalservices.ProcessorFallback {
+		xerror.Write(w, r, xerror.NewValidationError("name", "invalid processor name", nil))
+		return
+	}
+
+	params, xerr := parseSummaryWindow(r)
+	if xerr != nil {
+		xerror.Write(w, r, xerr)
+		return
+	}
+
+	summary, err := h.service.GetProcessorSummary(r.Context(), name, params)
+	if err != nil {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusBadGateway, "failed to fetch processor summary", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
+
 func (h *Handler) getPaymentsSummary(w http.ResponseWriter, r *http.Request) {
-	from := r.URL.Query().Get("from")
-	to := r.URL.Query().Get("to")
+	params, xerr := parseSummaryWindow(r)
+	if xerr != nil {
+		xerror.Write(w, r, xerr)
+		return
+	}
 
-	if (from != "" && to == "") || (from == "" && to != "") {
-		xerr := xerror.NewCustomError(http.StatusBadRequest, "both 'from' and 'to' must be provided, or neither", nil)
-		w.WriteHeader(xerr.Code)
-		json.NewEncoder(w).Encode(xerr)
+	// ?wait=<duration> pede o watermark (asOf/pendingCount); wait=0 só informa.
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil || d < 0 {
+			xerror.Write(w, r, xerror.NewValidationError("wait", "invalid 'wait' duration", err))
+			return
+		}
+		params.Watermark = true
+		params.Wait = d
+	}
+
+	summary, err := h.service.GetPaymentsSummary(r.Context(), params)
+	if err != nil {
+		xerror.Write(w, r, err)
 		return
 	}
 
-	params := PaymentSummaryParams{
-		Filter: from != "" && to != "",
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
+
+func (h *Handler) getPaymentsSummaryDetails(w http.ResponseWriter, r *http.Request) {
+	params, xerr := parseSummaryWindow(r)
+	if xerr != nil {
+		xerror.Write(w, r, xerr)
+		return
 	}
 
-	if params.Filter {
-		tFrom, err := time.Parse(time.RFC3339, from)
+	details, err := h.service.GetPaymentsSummaryDetails(r.Context(), params)
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(details)
+}
+
+func (h *Handler) getPaymentsTimeSeries(w http.ResponseWriter, r *http.Request) {
+	window, xerr := parseSummaryWindow(r)
+	if xerr == nil && !window.Filter {
+		xerr = xerror.NewCustomError(http.StatusBadRequest, "'from' and 'to' are required", nil)
+	}
+	if xerr != nil {
+		xerror.Write(w, r, xerr)
+		return
+	}
+
+	step, ok := timeSeriesSteps[r.URL.Query().Get("step")]
+	if !ok {
+		xerror.Write(w, r, xerror.NewValidationError("step", "invalid 'step', use 1s, 1m or 1h", nil))
+		return
+	}
+
+	if !window.To.After(window.From) || window.To.Sub(window.From)/step > maxTimeSeriesBuckets {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusBadRequest, "invalid window for the given 'step'", nil))
+		return
+	}
+
+	series, err := h.service.GetPaymentsTimeSeries(r.Context(), TimeSeriesParams{
+		From: window.From,
+		To:   window.To,
+		Step: step,
+	})
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(series)
+}
+
+func (h *Handler) exportPayments(w http.ResponseWriter, r *http.Request) {
+	params, xerr := parseSummaryWindow(r)
+	if xerr != nil {
+		xerror.Write(w, r, xerr)
+		return
+	}
+
+	format, err := ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		xerror.Write(w, r, xerror.NewValidationError("format", "invalid 'format', use csv or ndjson", err))
+		return
+	}
+
+	// Exportações longas não podem cair no WriteTimeout do servidor.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=payments.%s", format))
+	w.WriteHeader(http.StatusOK)
+
+	// Depois do primeiro byte não dá mais para trocar o status; só registramos.
+	if err := h.service.ExportPayments(r.Context(), params, format, w); err != nil {
+		serviceLog.ErrorContext(r.Context(), "fail on export payments", "error", err)
+	}
+}
+
+func (h *Handler) listPayments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := ListPaymentsParams{
+		Status:    PaymentStatus(q.Get("status")),
+		Processor: q.Get("processor"),
+		Cursor:    q.Get("cursor"),
+		Limit:     defaultListLimit,
+	}
+
+	var xerr *xerror.CustomError
+	switch {
+	case params.Status != "" && !params.Status.Valid():
+		xerr = xerror.NewValidationError("status", "invalid 'status'", nil)
+	case params.Processor != "" &&
+		params.Processor != string(externalservices.ProcessorDefault) &&
+		params.Processor != string(externalservices.ProcessorFallback):
+		xerr = xerror.NewValidationError("processor", "invalid 'processor'", nil)
+	}
+
+	if v := q.Get("limit"); xerr == nil && v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			xerr = xerror.NewValidationError("limit", fmt.Sprintf("'limit' must be between 1 and %d", maxListLimit), err)
+		}
+		params.Limit = limit
+	}
+
+	if v := q.Get("from"); xerr == nil && v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
 		if err != nil {
-			xerr := xerror.NewCustomError(http.StatusBadRequest, "invalid 'from' date", err)
-			w.WriteHeader(xerr.Code)
-			json.NewEncoder(w).Encode(xerr)
-			return
+			xerr = xerror.NewValidationError("from", "invalid 'from' date", err)
 		}
-		params.From = tFrom
+		params.From = t
+	}
 
-		tTo, err := time.Parse(time.RFC3339Nano, to)
+	if v := q.Get("to"); xerr == nil && v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
 		if err != nil {
-			xerr := xerror.NewCustomError(http.StatusBadRequest, "invalid 'to' date", err)
-			w.WriteHeader(xerr.Code)
-			json.NewEncoder(w).Encode(xerr)
-			return
+			xerr = xerror.NewValidationError("to", "invalid 'to' date", err)
 		}
-		params.To = tTo
+		params.To = t
 	}
 
-	summary, err := h.service.GetPaymentsSummary(r.Context(), params)
+	if xerr != nil {
+		xerror.Write(w, r, xerr)
+		return
+	}
+
+	page, err := h.service.ListPayments(r.Context(), params)
+	if errors.Is(err, ErrInvalidCursor) {
+		xerror.Write(w, r, xerror.NewValidationError("cursor", "invalid 'cursor'", err))
+		return
+	}
 	if err != nil {
-		xerr := xerror.NewCustomError(http.StatusInternalServerError, "internal server error", err)
-		w.WriteHeader(xerr.Code)
-		json.NewEncoder(w).Encode(xerr)
+		xerror.Write(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(summary)
+	json.NewEncoder(w).Encode(page)
+}
+
+// streamEvents envia as mudanças de status como Server-Sent Events. Com
+// Last-Event-ID (header ou ?lastEventId) os eventos perdidos são relidos do
+// stream no Redis antes de seguir ao vivo.
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := EventFilter{TenantID: tenant.FromContext(r.Context())}
+	for _, v := range splitList(q.Get("processor")) {
+		if v != string(externalservices.ProcessorDefault) && v != string(externalservices.ProcessorFallback) {
+			xerror.Write(w, r, xerror.NewValidationError("processor", "invalid 'processor'", nil))
+			return
+		}
+		filter.Processors = append(filter.Processors, v)
+	}
+	for _, v := range splitList(q.Get("status")) {
+		status := PaymentStatus(v)
+		if !status.Valid() {
+			xerror.Write(w, r, xerror.NewValidationError("status", "invalid 'status'", nil))
+			return
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = q.Get("lastEventId")
+	}
+	if lastID != "" {
+		if ms, _ := splitStreamID(lastID); ms == 0 {
+			xerror.Write(w, r, xerror.NewValidationError("Last-Event-ID", "invalid 'Last-Event-ID'", nil))
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	// Assina antes do replay para não perder eventos publicados no meio dele.
+	events, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		serviceLog.ErrorContext(r.Context(), "streaming not supported", "error", err)
+		return
+	}
+
+	send := func(e StatusEvent) error {
+		if !filter.Match(e.Payment) {
+			return nil
+		}
+		// O callbackUrl é do cliente que criou o pagamento; só o webhook usa.
+		e.Payment.CallbackURL = ""
+		data, err := json.Marshal(e.Payment)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %s\nevent: payment.status\ndata: %s\n\n", e.ID, data)
+		return err
+	}
+
+	if lastID != "" {
+		var err error
+		lastID, err = h.events.Replay(r.Context(), lastID, send)
+		if err != nil {
+			serviceLog.ErrorContext(r.Context(), "fail on replay payment events", "error", err)
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if lastID != "" && !streamIDAfter(e.ID, lastID) {
+				continue
+			}
+			lastID = e.ID
+			if err := send(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func splitList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
 }
 
 func (h *Handler) postPayment(w http.ResponseWriter, r *http.Request) {
 	var params PaymentParams
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		xerr := xerror.NewCustomError(http.StatusBadRequest, "invalid payment data", err)
-		w.WriteHeader(xerr.Code)
-		json.NewEncoder(w).Encode(xerr)
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusBadRequest, "invalid payment data", err))
+		return
+	}
+	if err := params.Validate(); err != nil {
+		xerror.Write(w, r, err)
 		return
 	}
-	
+	params.ClientID = r.Header.Get(ClientIDHeader)
+
 	payment, err := h.service.ProcessPayment(r.Context(), params)
 	if err != nil {
-		xerr := xerror.NewCustomError(http.StatusInternalServerError, "internal server error", err)
-		w.WriteHeader(xerr.Code)
-		json.NewEncoder(w).Encode(xerr)
+		xerror.Write(w, r, err)
 		return
 	}
 
@@ -120,10 +399,144 @@ func (h *Handler) postPayment(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(payment)
 }
 
-func SetupRoutes(r *chi.Mux, db *database.Redis) {
+func (h *Handler) getPayment(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.service.FindPayment(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payment)
+}
+
+func (h *Handler) postRefund(w http.ResponseWriter, r *http.Request) {
+	var params RefundParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusBadRequest, "invalid refund data", err))
+		return
+	}
+
+	refund, err := h.service.RefundPayment(r.Context(), chi.URLParam(r, "id"), params)
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(refund)
+}
+
+func (h *Handler) getRefunds(w http.ResponseWriter, r *http.Request) {
+	refunds, err := h.service.ListRefunds(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(refunds)
+}
+
+func (h *Handler) postCancel(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.service.CancelPayment(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// parseSummaryWindow lê a janela from/to usada pelos endpoints de summary.
+func parseSummaryWindow(r *http.Request) (PaymentSummaryParams, *xerror.CustomError) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+
+	if (from != "" && to == "") || (from == "" && to != "") {
+		return PaymentSummaryParams{}, xerror.NewCustomError(http.StatusBadRequest, "both 'from' and 'to' must be provided, or neither", nil)
+	}
+
+	params := PaymentSummaryParams{
+		Filter:     from != "" && to != "",
+		MerchantID: r.URL.Query().Get("merchantId"),
+	}
+
+	if len(params.MerchantID) > maxMerchantIDLength || !isIdentifier(params.MerchantID) {
+		return PaymentSummaryParams{}, xerror.NewValidationError("merchantId", "invalid 'merchantId'", nil)
+	}
+
+	if params.Filter {
+		tFrom, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return PaymentSummaryParams{}, xerror.NewValidationError("from", "invalid 'from' date", err)
+		}
+		params.From = tFrom
+
+		tTo, err := time.Parse(time.RFC3339Nano, to)
+		if err != nil {
+			return PaymentSummaryParams{}, xerror.NewValidationError("to", "invalid 'to' date", err)
+		}
+		params.To = tTo
+	}
+
+	return params, nil
+}
+
+func (h *Handler) postPaymentBatch(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+
+	decodeBody := DecodeBatchArray
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-ndjson") {
+		decodeBody = DecodeBatchNDJSON
+	}
+	// Corpo acima do limite é tratado como lote grande demais: nada é gravado.
+	decode := func(fn func(BatchItem) error) error {
+		err := decodeBody(body, fn)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return fmt.Errorf("%w: %w", ErrBatchTooLarge, err)
+		}
+		return err
+	}
+
+	result, err := h.service.ProcessPaymentBatch(r.Context(), r.Header.Get(ClientIDHeader), decode)
+	if err != nil && result.Error == "" {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, ErrBatchTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case err != nil:
+		status = http.StatusBadRequest
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+func SetupRoutes(r *chi.Mux, db *database.Redis, events *EventHub) {
 	repository := NewRepository(db)
-	handler := NewHandler(NewService(repository))
-	r.Get("/external-services/health", handler.getHealthStatus)
-	r.Get("/payments-summary", handler.getPaymentsSummary)
-	r.Post("/payments", handler.postPayment)
+	handler := NewHandler(NewService(repository), events)
+	admin := r.With(apikey.Require(apikey.ScopeAdmin))
+	read := r.With(apikey.Require(apikey.ScopeRead))
+	submit := r.With(apikey.Require(apikey.ScopeSubmit))
+	r.With(apikey.RequireGlobalAdmin()).Get("/external-services/health", handler.getHealthStatus)
+	admin.Get("/external-services/payments-summary", handler.getProcessorSummary)
+	read.Get("/payments-summary", handler.getPaymentsSummary)
+	read.Get("/payments-summary/details", handler.getPaymentsSummaryDetails)
+	read.Get("/payments-summary/timeseries", handler.getPaymentsTimeSeries)
+	submit.Post("/payments", handler.postPayment)
+	submit.Post("/payments/batch", handler.postPaymentBatch)
+	read.Get("/payments", handler.listPayments)
+	read.Get("/payments/export", handler.exportPayments)
+	read.Get("/payments/events", handler.streamEvents)
+	read.Get("/payments/{id}", handler.getPayment)
+	submit.Post("/payments/{id}/refund", handler.postRefund)
+	read.Get("/payments/{id}/refunds", handler.getRefunds)
+	submit.Post("/payments/{id}/cancel", handler.postCancel)
 }
@@ -1,28 +1,257 @@
 package payment
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
 	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/money"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/egress"
 )
 
 type PaymentStatus string
 
 const (
 	PaymentStatusPending PaymentStatus = "pending"
+	// PaymentStatusScheduled marca pagamentos com executeAt no futuro, que o
+	// scheduler ainda não colocou na fila.
+	PaymentStatusScheduled PaymentStatus = "scheduled"
 	PaymentStatusSuccess PaymentStatus = "success"
 	PaymentStatusFailed  PaymentStatus = "failed"
+	// PaymentStatusDead marca pagamentos descartados pelo worker (filas cheias).
+	PaymentStatusDead PaymentStatus = "dead"
+	// PaymentStatusCancelled marca pagamentos cancelados antes do envio ao processador.
+	PaymentStatusCancelled         PaymentStatus = "cancelled"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
 )
 
+var paymentStatuses = []PaymentStatus{
+	PaymentStatusPending,
+	PaymentStatusScheduled,
+	PaymentStatusSuccess,
+	PaymentStatusFailed,
+	PaymentStatusDead,
+	PaymentStatusCancelled,
+	PaymentStatusPartiallyRefunded,
+	PaymentStatusRefunded,
+}
+
+func (s PaymentStatus) Valid() bool {
+	return slices.Contains(paymentStatuses, s)
+}
+
 type Payment struct {
     CorrelationID string `json:"correlationId"`
     Amount        float64 `json:"amount"`
     Processor     string `json:"processor"`
     Status        PaymentStatus `json:"status"`
     StartedAt     time.Time `json:"startedAt"`
+    RequestedAt   time.Time `json:"requestedAt,omitzero"`
+    ExecuteAt     time.Time `json:"executeAt,omitzero"`
+    LatencyMs     int64 `json:"latencyMs,omitempty"`
+    CallbackURL   string `json:"callbackUrl,omitempty"`
+    ClientID      string `json:"clientId,omitempty"`
+    // RefundedAmount soma os estornos confirmados e os que estão em andamento.
+    RefundedAmount float64 `json:"refundedAmount,omitempty"`
+    MerchantID    string `json:"merchantId,omitempty"`
+    Description   string `json:"description,omitempty"`
+    Currency      string `json:"currency,omitempty"`
+    Metadata      map[string]string `json:"metadata,omitempty"`
+    // TenantID acompanha o pagamento pela fila, onde não há requisição, para
+    // que o worker use as chaves e os processadores do tenant certo.
+    TenantID      string `json:"tenantId,omitempty"`
+}
+
+// Finished indica se o pagamento chegou a um estado que não muda mais.
+// PaymentStatusFailed não é terminal porque o worker reprocessa a falha.
+func (p Payment) Finished() bool {
+    return p.Status == PaymentStatusSuccess || p.Status == PaymentStatusDead || p.Status == PaymentStatusCancelled
+}
+
+// settled indica que o processador confirmou o pagamento, mesmo que ele já
+// tenha sido estornado depois.
+func (p Payment) settled() bool {
+    switch p.Status {
+    case PaymentStatusSuccess, PaymentStatusPartiallyRefunded, PaymentStatusRefunded:
+        return true
+    default:
+        return false
+    }
+}
+
+// timestamp é o instante usado como score no sorted set "payments". Quando o
+// pagamento já foi enviado ao processador usamos exatamente o requestedAt
+// enviado, para que as janelas from/to batam com o summary do processador.
+func (p Payment) timestamp() time.Time {
+    if !p.RequestedAt.IsZero() {
+        return p.RequestedAt
+    }
+    return p.StartedAt
+}
+
+// LogValue limita o que vai para os logs: description, metadata e
+// callbackUrl ficam de fora, e correlationId e os valores passam pelo
+// mascaramento de LOG_REDACT.
+func (p Payment) LogValue() slog.Value {
+    return slog.GroupValue(
+        slog.String("correlationId", p.CorrelationID),
+        slog.Float64("amount", p.Amount),
+        slog.Float64("refundedAmount", p.RefundedAmount),
+        slog.String("status", string(p.Status)),
+        slog.String("processor", p.Processor),
+        slog.String("tenantId", p.TenantID),
+        slog.String("clientId", p.ClientID),
+        slog.String("merchantId", p.MerchantID),
+        slog.Time("requestedAt", p.RequestedAt),
+    )
+}
+
+type TimestampPolicy string
+
+const (
+    // TimestampAtReceipt carimba o requestedAt no momento em que o POST /payments chega.
+    TimestampAtReceipt TimestampPolicy = "receipt"
+    // TimestampAtDispatch carimba o requestedAt no momento do envio ao processador.
+    TimestampAtDispatch TimestampPolicy = "dispatch"
+)
+
+func ParseTimestampPolicy(v string) (TimestampPolicy, error) {
+    switch TimestampPolicy(v) {
+    case "", TimestampAtReceipt:
+        return TimestampAtReceipt, nil
+    case TimestampAtDispatch:
+        return TimestampAtDispatch, nil
+    default:
+        return "", fmt.Errorf("invalid timestamp policy %q", v)
+    }
 }
 
 type PaymentParams struct {
     CorrelationID string `json:"correlationId"`
     Amount        float64 `json:"amount"`
+    // CallbackURL recebe um webhook assinado quando o pagamento termina.
+    CallbackURL   string `json:"callbackUrl,omitempty"`
+    // ClientID vem do header X-Client-Id e seleciona o webhook do cliente.
+    ClientID      string `json:"-"`
+    // ExecuteAt agenda o envio ao processador; no passado o envio é imediato.
+    ExecuteAt     time.Time `json:"executeAt,omitzero"`
+    MerchantID    string `json:"merchantId,omitempty"`
+    Description   string `json:"description,omitempty"`
+    // Currency é um código ISO 4217 em maiúsculas, ex.: "BRL".
+    Currency      string `json:"currency,omitempty"`
+    Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+const (
+    maxMerchantIDLength    = 64
+    maxDescriptionLength   = 255
+    maxMetadataKeys        = 20
+    maxMetadataKeyLength   = 40
+    maxMetadataValueLength = 500
+)
+
+// validCorrelationID aceita só a forma canônica do UUID: uuid.Parse também
+// aceita "urn:uuid:..." e "{...}". O id vira chave no Redis, e qualquer outra
+// forma poderia apontar para o namespace de outro tenant.
+func validCorrelationID(id string) bool {
+    _, err := uuid.Parse(id)
+    return err == nil && len(id) == 36
+}
+
+func (p PaymentParams) Validate() error {
+    if !validCorrelationID(p.CorrelationID) {
+        return ErrInvalidCorrelationID
+    }
+    if p.Amount <= 0 || money.ToCents(p.Amount) <= 0 {
+        return ErrInvalidAmount
+    }
+    if err := p.validateCallbackURL(); err != nil {
+        return err
+    }
+    return p.validateAttributes()
+}
+
+// validateAttributes confere os campos opcionais de atribuição do pagamento.
+func (p PaymentParams) validateAttributes() error {
+    if len(p.MerchantID) > maxMerchantIDLength || !isIdentifier(p.MerchantID) {
+        return ErrInvalidMerchantID
+    }
+    if utf8.RuneCountInString(p.Description) > maxDescriptionLength {
+        return ErrInvalidDescription
+    }
+    if p.Currency != "" && !isCurrencyCode(p.Currency) {
+        return ErrInvalidCurrency
+    }
+    if len(p.Metadata) > maxMetadataKeys {
+        return ErrInvalidMetadata
+    }
+    for k, v := range p.Metadata {
+        if k == "" || len(k) > maxMetadataKeyLength || !isIdentifier(k) || utf8.RuneCountInString(v) > maxMetadataValueLength {
+            return ErrInvalidMetadata
+        }
+    }
+    return nil
+}
+
+// isIdentifier aceita letras, dígitos, '-', '_' e '.'.
+func isIdentifier(v string) bool {
+    for _, c := range v {
+        switch {
+        case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+        default:
+            return false
+        }
+    }
+    return true
+}
+
+func isCurrencyCode(v string) bool {
+    if len(v) != 3 {
+        return false
+    }
+    for _, c := range v {
+        if c < 'A' || c > 'Z' {
+            return false
+        }
+    }
+    return true
+}
+
+// validateCallbackURL também resolve o host: o webhook não pode apontar para
+// o Redis, os processadores ou outro endereço interno.
+func (p PaymentParams) validateCallbackURL() error {
+    if p.CallbackURL == "" {
+        return nil
+    }
+    if err := egress.CheckURL(context.Background(), p.CallbackURL); err != nil {
+        return fmt.Errorf("%w: %w", ErrInvalidCallbackURL, err)
+    }
+    return nil
+}
+
+func (p PaymentParams) toPayment() Payment {
+    payment := Payment{
+        CorrelationID: p.CorrelationID,
+        Amount:        p.Amount,
+        Status:        PaymentStatusPending,
+        StartedAt:     time.Now(),
+        CallbackURL:   p.CallbackURL,
+        ClientID:      p.ClientID,
+        MerchantID:    p.MerchantID,
+        Description:   p.Description,
+        Currency:      p.Currency,
+        Metadata:      p.Metadata,
+    }
+    if p.ExecuteAt.After(payment.StartedAt) {
+        payment.Status = PaymentStatusScheduled
+        payment.ExecuteAt = p.ExecuteAt.UTC()
+    }
+    return payment
 }
 
 type totalPayments struct {
@@ -34,9 +263,119 @@ type PaymentSummaryParams struct {
     Filter bool `json:"filter"`
     From time.Time `json:"from"`
     To time.Time `json:"to"`
+    // Watermark inclui asOf/pendingCount na resposta e, se Wait > 0, espera
+    // até que nenhum pagamento com requestedAt <= To esteja em voo.
+    Watermark bool `json:"watermark"`
+    Wait time.Duration `json:"wait"`
+    // MerchantID restringe o summary aos pagamentos de um merchant.
+    MerchantID string `json:"merchantId"`
 }
 
 type PaymentSummary struct {
     Default totalPayments `json:"default"`
     Fallback totalPayments `json:"fallback"`
+    AsOf time.Time `json:"asOf,omitzero"`
+    PendingCount *int64 `json:"pendingCount,omitempty"`
+}
+
+type StatusTotals struct {
+    Count  int `json:"count"`
+    Amount float64 `json:"amount"`
+}
+
+type LatencyPercentiles struct {
+    P50 int64 `json:"p50Ms"`
+    P95 int64 `json:"p95Ms"`
+    P99 int64 `json:"p99Ms"`
+}
+
+type ProcessorSummary struct {
+    totalPayments
+    ByStatus     map[PaymentStatus]StatusTotals `json:"byStatus"`
+    FailedAmount float64 `json:"failedAmount"`
+    DeadAmount   float64 `json:"deadAmount"`
+    // RefundedAmount é o total estornado; NetAmount = totalAmount - refundedAmount.
+    RefundedAmount float64 `json:"refundedAmount"`
+    NetAmount      float64 `json:"netAmount"`
+    EstimatedFee float64 `json:"estimatedFee"`
+    Latency      LatencyPercentiles `json:"latency"`
+}
+
+// PaymentSummaryDetails é a versão estendida do PaymentSummary. Unassigned
+// agrupa pagamentos que nunca chegaram a um processador (pending/dead).
+type PaymentSummaryDetails struct {
+    Default    ProcessorSummary `json:"default"`
+    Fallback   ProcessorSummary `json:"fallback"`
+    Unassigned ProcessorSummary `json:"unassigned"`
+}
+
+type TimeSeriesParams struct {
+    From time.Time `json:"from"`
+    To   time.Time `json:"to"`
+    Step time.Duration `json:"step"`
+}
+
+type TimeSeriesBucket struct {
+    Start    time.Time `json:"start"`
+    Default  totalPayments `json:"default"`
+    Fallback totalPayments `json:"fallback"`
+}
+
+type PaymentTimeSeries struct {
+    Step    string `json:"step"`
+    Buckets []TimeSeriesBucket `json:"buckets"`
+}
+
+type ListPaymentsParams struct {
+    Status    PaymentStatus `json:"status"`
+    Processor string `json:"processor"`
+    From      time.Time `json:"from"`
+    To        time.Time `json:"to"`
+    Cursor    string `json:"cursor"`
+    Limit     int `json:"limit"`
+}
+
+type PaymentPage struct {
+    Items      []Payment `json:"items"`
+    NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type BatchItemStatus string
+
+const (
+    BatchItemAccepted  BatchItemStatus = "accepted"
+    BatchItemDuplicate BatchItemStatus = "duplicate"
+    BatchItemInvalid   BatchItemStatus = "invalid"
+    // BatchItemRejected indica que o pagamento foi gravado mas a fila estava cheia.
+    BatchItemRejected BatchItemStatus = "rejected"
+)
+
+type BatchItemResult struct {
+    Index         int `json:"index"`
+    CorrelationID string `json:"correlationId,omitempty"`
+    Status        BatchItemStatus `json:"status"`
+    Error         string `json:"error,omitempty"`
+}
+
+type BatchResult struct {
+    Accepted  int `json:"accepted"`
+    Duplicate int `json:"duplicate"`
+    Invalid   int `json:"invalid"`
+    Rejected  int `json:"rejected"`
+    Results   []BatchItemResult `json:"results"`
+    Error     string `json:"error,omitempty"`
+}
+
+func (b *BatchResult) add(item BatchItemResult) {
+    switch item.Status {
+    case BatchItemAccepted:
+        b.Accepted++
+    case BatchItemDuplicate:
+        b.Duplicate++
+    case BatchItemInvalid:
+        b.Invalid++
+    case BatchItemRejected:
+        b.Rejected++
+    }
+    b.Results = append(b.Results, item)
 }
@@ -0,0 +1,181 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/money"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+type Refund struct {
+	ID            string       `json:"id"`
+	CorrelationID string       `json:"correlationId"`
+	Amount        float64      `json:"amount"`
+	Processor     string       `json:"processor"`
+	Status        RefundStatus `json:"status"`
+	Error         string       `json:"error,omitempty"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	ProcessedAt   time.Time    `json:"processedAt,omitzero"`
+}
+
+// RefundParams é o corpo de POST /payments/{id}/refund. Sem amount o estorno
+// é do saldo restante.
+type RefundParams struct {
+	Amount *float64 `json:"amount,omitempty"`
+}
+
+// CancelPayment cancela um pagamento que ainda não foi enviado ao processador.
+// A transição é atômica com o claim feito pelo worker antes do envio, então um
+// pagamento cancelado nunca chega ao processador.
+func (s *Service) CancelPayment(ctx context.Context, id string) (Payment, error) {
+	if !validCorrelationID(id) {
+		return Payment{}, ErrPaymentNotFound
+	}
+	if err := s.r.CancelPayment(ctx, id); err != nil {
+		return Payment{}, err
+	}
+
+	p, err := s.r.FindPaymentByID(ctx, id)
+	if err != nil {
+		return Payment{}, err
+	}
+	// Regrava para atualizar índices, summary e stream de eventos.
+	if err := s.r.SavePayment(ctx, p); err != nil {
+		return Payment{}, err
+	}
+	return p, nil
+}
+
+// RefundPayment estorna total ou parcialmente um pagamento confirmado. O valor
+// é reservado no hash antes de chamar o processador, para que estornos
+// concorrentes não ultrapassem o valor pago, e devolvido se o processador
+// recusar.
+func (s *Service) RefundPayment(ctx context.Context, id string, params RefundParams) (Refund, error) {
+	p, err := s.FindPayment(ctx, id)
+	if err != nil {
+		return Refund{}, err
+	}
+	if !p.settled() {
+		return Refund{}, ErrPaymentNotRefundable
+	}
+
+	processors, err := s.processorsFor(ctx)
+	if err != nil {
+		return Refund{}, err
+	}
+	refunder, ok := processors.ByName(externalservices.ProcessorName(p.Processor)).(externalservices.Refunder)
+	if !ok {
+		return Refund{}, ErrRefundNotSupported
+	}
+
+	amount := p.Amount - p.RefundedAmount
+	if params.Amount != nil {
+		amount = *params.Amount
+	}
+	cents := money.ToCents(amount)
+	if cents <= 0 {
+		return Refund{}, ErrInvalidAmount
+	}
+
+	if err := s.r.ReserveRefund(ctx, id, cents); err != nil {
+		return Refund{}, err
+	}
+
+	refund := Refund{
+		ID:            uuid.NewString(),
+		CorrelationID: id,
+		Amount:        money.ToFloat(cents),
+		Processor:     p.Processor,
+		Status:        RefundStatusPending,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := s.r.SaveRefund(ctx, refund); err != nil {
+		s.releaseRefund(ctx, id, cents)
+		return Refund{}, err
+	}
+
+	_, refundErr := refunder.RefundPayment(ctx, externalservices.RefundParams{
+		CorrelationID: id,
+		RefundID:      refund.ID,
+		Amount:        refund.Amount,
+		RequestedAt:   refund.CreatedAt.Truncate(time.Millisecond),
+	})
+
+	// O processador já respondeu; o resultado precisa ser gravado mesmo que o
+	// cliente tenha desistido da requisição.
+	ctx = context.WithoutCancel(ctx)
+	refund.ProcessedAt = time.Now().UTC()
+	if refundErr != nil {
+		serviceLog.Error("failed to refund payment", "error", refundErr, "correlation_id", id, "refund_id", refund.ID)
+		refund.Status = RefundStatusFailed
+		refund.Error = refundErr.Error()
+		s.releaseRefund(ctx, id, cents)
+	} else {
+		refund.Status = RefundStatusSucceeded
+	}
+
+	if err := s.r.SaveRefund(ctx, refund); err != nil {
+		return Refund{}, err
+	}
+	if err := s.syncRefundStatus(ctx, id); err != nil {
+		return Refund{}, err
+	}
+
+	if refundErr != nil {
+		return refund, fmt.Errorf("%w: %w", ErrRefundFailed, refundErr)
+	}
+	return refund, nil
+}
+
+func (s *Service) ListRefunds(ctx context.Context, id string) ([]Refund, error) {
+	if _, err := s.FindPayment(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.r.ListRefunds(ctx, id)
+}
+
+func (s *Service) releaseRefund(ctx context.Context, id string, cents int) {
+	if err := s.r.ReleaseRefund(ctx, id, cents); err != nil {
+		serviceLog.Error("failed to release refund reservation", "error", err, "correlation_id", id, "amount", cents)
+	}
+}
+
+// syncRefundStatus recalcula o status do pagamento a partir do total estornado
+// gravado no hash, que é a fonte de verdade quando há estornos concorrentes.
+func (s *Service) syncRefundStatus(ctx context.Context, id string) error {
+	p, err := s.r.FindPaymentByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !p.settled() {
+		return nil
+	}
+
+	status := PaymentStatusSuccess
+	switch refunded := money.ToCents(p.RefundedAmount); {
+	case refunded >= money.ToCents(p.Amount):
+		status = PaymentStatusRefunded
+	case refunded > 0:
+		status = PaymentStatusPartiallyRefunded
+	}
+	// Nada foi estornado: regravar criaria outro membro "success" e o
+	// pagamento contaria duas vezes no summary.
+	if status == PaymentStatusSuccess && p.Status == PaymentStatusSuccess {
+		return nil
+	}
+	// Nos demais casos regrava mesmo sem mudar o status: o membro novo leva o
+	// refundedAmount atualizado para o summary.
+	p.Status = status
+	return s.r.SavePayment(ctx, p)
+}
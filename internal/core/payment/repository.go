@@ -4,23 +4,113 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"maps"
+	"math"
+	"slices"
 	"strconv"
 	"time"
 
 	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
 	"github.com/oprimogus/rinha-backend-2025/internal/core/money"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
 	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
 	"github.com/redis/go-redis/v9"
 )
 
+// Todas as chaves passam por tenant.Key, que as prefixa com o tenant do
+// contexto. O stream de eventos é o único namespace compartilhado.
+const (
+	paymentsKey        = "payments"
+	pendingPaymentsKey = "payments:pending"
+	// Pagamentos agendados: membro é o correlationId e o score, em ms, é o
+	// executeAt ou o fim do lease de quem reivindicou o pagamento.
+	scheduledPaymentsKey = "payments:scheduled"
+
+	// Índices secundários para a listagem: membro é o correlationId e o score
+	// é o mesmo timestamp do sorted set "payments".
+	timeIndexKey            = "payments:index:time"
+	statusIndexKeyPrefix    = "payments:index:status:"
+	processorIndexKeyPrefix = "payments:index:processor:"
+
+	refundKeyPrefix         = "refunds:"
+	paymentRefundsKeyPrefix = "payments:refunds:"
+
+	scanPageSize = 500
+)
+
 type Repository interface {
 	FindPaymentByID(ctx context.Context, id string) (Payment, error)
 	FindProcessorHealth(ctx context.Context, name externalservices.ProcessorName) (externalservices.HealthCheckResponse, error)
 	SavePayment(ctx context.Context, payment Payment) error
+	CreatePayments(ctx context.Context, payments []Payment) ([]bool, error)
 	SaveProcessorHealthStatus(ctx context.Context, name externalservices.ProcessorName, status externalservices.HealthCheckResponse) error
 	GetPaymentsSummary(ctx context.Context, params PaymentSummaryParams) (PaymentSummary, error)
+	GetPaymentsSummaryDetails(ctx context.Context, params PaymentSummaryParams) (PaymentSummaryDetails, error)
+	GetPaymentsTimeSeries(ctx context.Context, params TimeSeriesParams) (PaymentTimeSeries, error)
+	ScanPayments(ctx context.Context, params PaymentSummaryParams, fn func(page []Payment) error) error
+	ListPayments(ctx context.Context, params ListPaymentsParams) (PaymentPage, error)
+	CountPendingPayments(ctx context.Context, until time.Time) (int64, error)
+	ClaimPaymentDispatch(ctx context.Context, id string) (bool, error)
+	CancelPayment(ctx context.Context, id string) error
+	ClaimDueScheduledPayments(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]string, error)
+	UnschedulePayment(ctx context.Context, id string) error
+	ReserveRefund(ctx context.Context, id string, cents int) error
+	ReleaseRefund(ctx context.Context, id string, cents int) error
+	SaveRefund(ctx context.Context, refund Refund) error
+	ListRefunds(ctx context.Context, id string) ([]Refund, error)
 }
 
+// createPayment cria o hash inteiro do pagamento, se ele ainda não existir,
+// num passo só: ninguém chega a ler um hash pela metade.
+var createPayment = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then return 0 end
+redis.call('HSET', KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// claimDispatch marca o pagamento como enviado ao processador, a menos que ele
+// já tenha sido cancelado. Hashes inexistentes não são criados.
+var claimDispatch = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'status') == 'cancelled' then return 0 end
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  redis.call('HSET', KEYS[1], 'dispatchedAt', ARGV[1])
+end
+return 1
+`)
+
+// cancelPayment só cancela pagamentos pendentes ou agendados que nenhum
+// worker reivindicou, e os tira do scheduler.
+var cancelPayment = redis.NewScript(`
+local status = redis.call('HGET', KEYS[1], 'status')
+if not status then return -1 end
+if (status ~= 'pending' and status ~= 'scheduled') or redis.call('HEXISTS', KEYS[1], 'dispatchedAt') == 1 then return 0 end
+redis.call('HSET', KEYS[1], 'status', 'cancelled')
+redis.call('ZREM', KEYS[2], ARGV[1])
+return 1
+`)
+
+// claimScheduled devolve até ARGV[3] pagamentos vencidos e empurra o score
+// deles para ARGV[2], o fim do lease. Se a instância cair antes de enfileirar,
+// outra reivindica o pagamento quando o lease expirar.
+var claimScheduled = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+for _, id in ipairs(ids) do
+  redis.call('ZADD', KEYS[1], ARGV[2], id)
+end
+return ids
+`)
+
+// reserveRefund soma o estorno ao refundedAmount (centavos) se o pagamento foi
+// confirmado e o total não passa do valor pago.
+var reserveRefund = redis.NewScript(`
+local status = redis.call('HGET', KEYS[1], 'status')
+if not status then return -1 end
+if status ~= 'success' and status ~= 'partially_refunded' and status ~= 'refunded' then return -2 end
+local refunded = tonumber(redis.call('HGET', KEYS[1], 'refundedAmount') or '0')
+if refunded + tonumber(ARGV[1]) > tonumber(redis.call('HGET', KEYS[1], 'amount')) then return -3 end
+return redis.call('HINCRBY', KEYS[1], 'refundedAmount', ARGV[1])
+`)
+
 type repository struct {
 	rdb *database.Redis
 }
@@ -32,15 +122,19 @@ func NewRepository(redis *database.Redis) Repository {
 }
 
 func (r *repository) FindPaymentByID(ctx context.Context, id string) (Payment, error) {
-	v, err := r.rdb.HGetAll(ctx, id).Result()
+	v, err := r.rdb.HGetAll(ctx, tenant.Key(ctx, id)).Result()
 	if err != nil {
 		return Payment{}, err
 	}
 
 	if len(v) == 0 {
-		return Payment{}, errors.New("payment not found")
+		return Payment{}, ErrPaymentNotFound
 	}
 
+	return paymentFromHash(id, v)
+}
+
+func paymentFromHash(id string, v map[string]string) (Payment, error) {
 	m, err := money.FromStringToFloat(v["amount"])
 	if err != nil {
 		return Payment{}, err
@@ -51,40 +145,497 @@ func (r *repository) FindPaymentByID(ctx context.Context, id string) (Payment, e
 		return Payment{}, err
 	}
 
-	return Payment{
+	p := Payment{
 		CorrelationID: id,
 		Amount:        m,
 		Processor:     v["processor"],
 		Status:        PaymentStatus(v["status"]),
 		StartedAt:     t,
-	}, nil
+	}
+
+	if ra, ok := v["requestedAt"]; ok && ra != "" {
+		p.RequestedAt, err = time.Parse(time.RFC3339Nano, ra)
+		if err != nil {
+			return Payment{}, err
+		}
+	}
+
+	if ea, ok := v["executeAt"]; ok && ea != "" {
+		p.ExecuteAt, err = time.Parse(time.RFC3339Nano, ea)
+		if err != nil {
+			return Payment{}, err
+		}
+	}
+
+	if ra, ok := v["refundedAmount"]; ok && ra != "" {
+		p.RefundedAmount, err = money.FromStringToFloat(ra)
+		if err != nil {
+			return Payment{}, err
+		}
+	}
+
+	p.CallbackURL = v["callbackUrl"]
+	p.ClientID = v["clientId"]
+	p.TenantID = v["tenantId"]
+	p.MerchantID = v["merchantId"]
+	p.Description = v["description"]
+	p.Currency = v["currency"]
+
+	if md, ok := v["metadata"]; ok && md != "" {
+		if err := json.Unmarshal([]byte(md), &p.Metadata); err != nil {
+			return Payment{}, err
+		}
+	}
+
+	if l, ok := v["latencyMs"]; ok && l != "" {
+		p.LatencyMs, err = strconv.ParseInt(l, 10, 64)
+		if err != nil {
+			return Payment{}, err
+		}
+	}
+
+	return p, nil
 }
 
 func (r *repository) SavePayment(ctx context.Context, payment Payment) error {
-	body := map[string]any{
-		"amount":    money.ToCents(payment.Amount),
-		"processor": payment.Processor,
-		"status":    string(payment.Status),
-		"startedAt": payment.StartedAt,
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		return savePayment(ctx, pipe, payment, false)
+	})
+	return err
+}
+
+// CreatePayments grava os pagamentos que ainda não existem. O createPayment
+// garante que, mesmo com duas instâncias recebendo o mesmo lote, cada
+// correlationId é criado uma única vez; os índices e o evento vêm depois, só
+// para os criados. O retorno indica, por posição, quais pagamentos foram
+// criados.
+func (r *repository) CreatePayments(ctx context.Context, payments []Payment) ([]bool, error) {
+	created := make([]bool, len(payments))
+	if len(payments) == 0 {
+		return created, nil
 	}
 
-	if err := r.rdb.HSet(ctx, payment.CorrelationID, body).Err(); err != nil {
-		return err
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.Cmd, len(payments))
+	for i, p := range payments {
+		body, _, err := paymentFields(p)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]any, 0, 2*len(body))
+		for field, value := range body {
+			args = append(args, field, value)
+		}
+		cmds[i] = createPayment.Eval(ctx, pipe, []string{tenant.Key(ctx, p.CorrelationID)}, args...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
 	}
 
-	jsonData, err := json.Marshal(body)
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, p := range payments {
+			n, err := cmds[i].Int()
+			if err != nil {
+				return err
+			}
+			created[i] = n == 1
+			if !created[i] {
+				continue
+			}
+			if err := savePayment(ctx, pipe, p, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// savePayment publica o evento só quando o status muda. O pagamento recém
+// criado (created) já tem o hash com o mesmo status, mas o evento ainda não
+// saiu.
+func savePayment(ctx context.Context, pipe redis.Pipeliner, payment Payment, created bool) error {
+	body, member, err := paymentFields(payment)
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(member)
 	if err != nil {
 		return err
 	}
 
-	return r.rdb.ZAdd(ctx, "payments", redis.Z{
-		Score:  float64(payment.StartedAt.UnixNano()),
+	publishEvent.Eval(ctx, pipe, []string{EventsStream, EventsChannel, tenant.Key(ctx, payment.CorrelationID)},
+		eventsStreamMaxLen, jsonData, string(payment.Status), created)
+
+	pipe.HSet(ctx, tenant.Key(ctx, payment.CorrelationID), body)
+	pipe.ZAdd(ctx, tenant.Key(ctx, paymentsKey), redis.Z{
+		Score:  float64(payment.timestamp().UnixNano()),
 		Member: jsonData,
-	}).Err()
+	})
+
+	// Índice dos pagamentos ainda em voo, usado como watermark do summary. O
+	// score é o startedAt truncado em milissegundos, como o requestedAt: sem o
+	// truncamento ele podia ficar depois do requestedAt e o pagamento escapava
+	// de um summary com to igual ao requestedAt. Só um status final tira o
+	// pagamento do índice; failed e scheduled ainda vão ser enviados.
+	switch {
+	case payment.Status == PaymentStatusPending:
+		pipe.ZAdd(ctx, tenant.Key(ctx, pendingPaymentsKey), redis.Z{
+			Score:  float64(payment.StartedAt.Truncate(time.Millisecond).UnixNano()),
+			Member: payment.CorrelationID,
+		})
+	case payment.Finished():
+		pipe.ZRem(ctx, tenant.Key(ctx, pendingPaymentsKey), payment.CorrelationID)
+	}
+
+	if payment.Status == PaymentStatusScheduled {
+		pipe.ZAddNX(ctx, tenant.Key(ctx, scheduledPaymentsKey), redis.Z{
+			Score:  float64(payment.ExecuteAt.UnixMilli()),
+			Member: payment.CorrelationID,
+		})
+	}
+
+	saveIndexes(ctx, pipe, payment)
+	return nil
+}
+
+// paymentFields monta o hash do pagamento (body) e o membro do sorted set
+// usado pelo summary (member).
+func paymentFields(payment Payment) (body, member map[string]any, err error) {
+	body = map[string]any{
+		"correlationId": payment.CorrelationID,
+		"amount":        money.ToCents(payment.Amount),
+		"processor":     payment.Processor,
+		"status":        string(payment.Status),
+		"startedAt":     payment.StartedAt,
+	}
+	if !payment.RequestedAt.IsZero() {
+		body["requestedAt"] = payment.RequestedAt
+	}
+	if !payment.ExecuteAt.IsZero() {
+		body["executeAt"] = payment.ExecuteAt
+	}
+	if payment.LatencyMs > 0 {
+		body["latencyMs"] = payment.LatencyMs
+	}
+	if payment.CallbackURL != "" {
+		body["callbackUrl"] = payment.CallbackURL
+	}
+	if payment.ClientID != "" {
+		body["clientId"] = payment.ClientID
+	}
+	if payment.TenantID != "" {
+		body["tenantId"] = payment.TenantID
+	}
+	if payment.MerchantID != "" {
+		body["merchantId"] = payment.MerchantID
+	}
+	if payment.Currency != "" {
+		body["currency"] = payment.Currency
+	}
+
+	// O refundedAmount do hash é mantido só por reserveRefund; no membro ele
+	// vai para que o summary enxergue o valor estornado. O merchantId vai nos
+	// dois para filtrar o summary.
+	member = maps.Clone(body)
+	if payment.RefundedAmount > 0 {
+		member["refundedAmount"] = money.ToCents(payment.RefundedAmount)
+	}
+
+	// Descrição e metadata ficam só no hash para não inflar o sorted set.
+	if payment.Description != "" {
+		body["description"] = payment.Description
+	}
+	if len(payment.Metadata) > 0 {
+		metadata, err := json.Marshal(payment.Metadata)
+		if err != nil {
+			return nil, nil, err
+		}
+		body["metadata"] = metadata
+	}
+	return body, member, nil
+}
+
+// saveIndexes usa o startedAt como score: ele não muda depois da criação, ao
+// contrário do requestedAt, então o pagamento não troca de posição no meio de
+// uma paginação quando é enviado ao processador.
+func saveIndexes(ctx context.Context, pipe redis.Pipeliner, payment Payment) {
+	z := redis.Z{
+		Score:  float64(payment.StartedAt.UnixNano()),
+		Member: payment.CorrelationID,
+	}
+
+	pipe.ZAdd(ctx, tenant.Key(ctx, timeIndexKey), z)
+
+	for _, status := range paymentStatuses {
+		if status != payment.Status {
+			pipe.ZRem(ctx, tenant.Key(ctx, statusIndexKeyPrefix+string(status)), payment.CorrelationID)
+		}
+	}
+	pipe.ZAdd(ctx, tenant.Key(ctx, statusIndexKeyPrefix+string(payment.Status)), z)
+
+	for _, processor := range []externalservices.ProcessorName{externalservices.ProcessorDefault, externalservices.ProcessorFallback} {
+		if string(processor) != payment.Processor {
+			pipe.ZRem(ctx, tenant.Key(ctx, processorIndexKeyPrefix+string(processor)), payment.CorrelationID)
+		}
+	}
+	if payment.Processor != "" {
+		pipe.ZAdd(ctx, tenant.Key(ctx, processorIndexKeyPrefix+payment.Processor), z)
+	}
+}
+
+// ListPayments pagina os pagamentos em ordem de (startedAt, correlationId).
+// Com status e processor, o índice com menos membros é percorrido e o outro
+// filtro é aplicado sobre o hash do pagamento. Os dois índices usam o mesmo
+// score, então o cursor continua valendo se a escolha mudar entre páginas.
+func (r *repository) ListPayments(ctx context.Context, params ListPaymentsParams) (PaymentPage, error) {
+	key := tenant.Key(ctx, timeIndexKey)
+	statusKey := tenant.Key(ctx, statusIndexKeyPrefix+string(params.Status))
+	processorKey := tenant.Key(ctx, processorIndexKeyPrefix+params.Processor)
+	switch {
+	case params.Status != "" && params.Processor != "":
+		pipe := r.rdb.Pipeline()
+		statusCard := pipe.ZCard(ctx, statusKey)
+		processorCard := pipe.ZCard(ctx, processorKey)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return PaymentPage{}, err
+		}
+		key = statusKey
+		if processorCard.Val() < statusCard.Val() {
+			key = processorKey
+		}
+	case params.Status != "":
+		key = statusKey
+	case params.Processor != "":
+		key = processorKey
+	}
+
+	min, max := "-inf", "+inf"
+	if !params.From.IsZero() {
+		min = strconv.FormatInt(params.From.UnixNano(), 10)
+	}
+	if !params.To.IsZero() {
+		max = strconv.FormatInt(params.To.UnixNano(), 10)
+	}
+
+	var after *listCursor
+	if params.Cursor != "" {
+		c, err := decodeListCursor(params.Cursor)
+		if err != nil {
+			return PaymentPage{}, err
+		}
+		after = &c
+		min = strconv.FormatFloat(c.Score, 'f', -1, 64)
+	}
+
+	page := PaymentPage{Items: make([]Payment, 0, params.Limit)}
+	batch := int64(params.Limit)
+	var offset int64
+
+	for {
+		zs, err := r.rdb.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+			Min:    min,
+			Max:    max,
+			Offset: offset,
+			Count:  batch,
+		}).Result()
+		if err != nil {
+			return PaymentPage{}, err
+		}
+		offset += int64(len(zs))
+
+		candidates := zs[:0]
+		for _, z := range zs {
+			id, _ := z.Member.(string)
+			if after != nil && !after.precedes(z.Score, id) {
+				continue
+			}
+			candidates = append(candidates, z)
+		}
+
+		pipe := r.rdb.Pipeline()
+		cmds := make([]*redis.MapStringStringCmd, len(candidates))
+		for i, z := range candidates {
+			cmds[i] = pipe.HGetAll(ctx, tenant.Key(ctx, z.Member.(string)))
+		}
+		if len(cmds) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+				return PaymentPage{}, err
+			}
+		}
+
+		for i, z := range candidates {
+			id := z.Member.(string)
+			v := cmds[i].Val()
+			if len(v) == 0 {
+				continue
+			}
+			p, err := paymentFromHash(id, v)
+			if err != nil {
+				continue
+			}
+			if params.Processor != "" && p.Processor != params.Processor {
+				continue
+			}
+			if params.Status != "" && p.Status != params.Status {
+				continue
+			}
+
+			page.Items = append(page.Items, p)
+			if len(page.Items) == params.Limit {
+				page.NextCursor = encodeListCursor(listCursor{Score: z.Score, ID: id})
+				return page, nil
+			}
+		}
+
+		if int64(len(zs)) < batch {
+			return page, nil
+		}
+	}
+}
+
+func (r *repository) CountPendingPayments(ctx context.Context, until time.Time) (int64, error) {
+	max := "+inf"
+	if !until.IsZero() {
+		max = strconv.FormatInt(until.UnixNano(), 10)
+	}
+	return r.rdb.ZCount(ctx, tenant.Key(ctx, pendingPaymentsKey), "-inf", max).Result()
+}
+
+func (r *repository) ClaimPaymentDispatch(ctx context.Context, id string) (bool, error) {
+	n, err := claimDispatch.Run(ctx, r.rdb, []string{tenant.Key(ctx, id)}, time.Now().UTC()).Int()
+	return n == 1, err
+}
+
+func (r *repository) CancelPayment(ctx context.Context, id string) error {
+	n, err := cancelPayment.Run(ctx, r.rdb, []string{tenant.Key(ctx, id), tenant.Key(ctx, scheduledPaymentsKey)}, id).Int()
+	switch {
+	case err != nil:
+		return err
+	case n == -1:
+		return ErrPaymentNotFound
+	case n == 0:
+		return ErrPaymentNotCancellable
+	}
+	return nil
+}
+
+func (r *repository) ClaimDueScheduledPayments(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]string, error) {
+	return claimScheduled.Run(ctx, r.rdb, []string{tenant.Key(ctx, scheduledPaymentsKey)},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), limit).StringSlice()
+}
+
+func (r *repository) UnschedulePayment(ctx context.Context, id string) error {
+	return r.rdb.ZRem(ctx, tenant.Key(ctx, scheduledPaymentsKey), id).Err()
+}
+
+func (r *repository) ReserveRefund(ctx context.Context, id string, cents int) error {
+	n, err := reserveRefund.Run(ctx, r.rdb, []string{tenant.Key(ctx, id)}, cents).Int()
+	switch {
+	case err != nil:
+		return err
+	case n == -1:
+		return ErrPaymentNotFound
+	case n == -2:
+		return ErrPaymentNotRefundable
+	case n == -3:
+		return ErrRefundExceedsAmount
+	}
+	return nil
+}
+
+func (r *repository) ReleaseRefund(ctx context.Context, id string, cents int) error {
+	return r.rdb.HIncrBy(ctx, tenant.Key(ctx, id), "refundedAmount", int64(-cents)).Err()
+}
+
+// SaveRefund grava o estorno e, na primeira gravação, o registra na lista de
+// estornos do pagamento.
+func (r *repository) SaveRefund(ctx context.Context, refund Refund) error {
+	body := map[string]any{
+		"id":            refund.ID,
+		"correlationId": refund.CorrelationID,
+		"amount":        money.ToCents(refund.Amount),
+		"processor":     refund.Processor,
+		"status":        string(refund.Status),
+		"error":         refund.Error,
+		"createdAt":     refund.CreatedAt,
+		"processedAt":   "",
+	}
+	if !refund.ProcessedAt.IsZero() {
+		body["processedAt"] = refund.ProcessedAt
+	}
+
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, tenant.Key(ctx, refundKeyPrefix+refund.ID), body)
+		if refund.Status == RefundStatusPending {
+			pipe.RPush(ctx, tenant.Key(ctx, paymentRefundsKeyPrefix+refund.CorrelationID), refund.ID)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *repository) ListRefunds(ctx context.Context, id string) ([]Refund, error) {
+	ids, err := r.rdb.LRange(ctx, tenant.Key(ctx, paymentRefundsKeyPrefix+id), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, refundID := range ids {
+		cmds[i] = pipe.HGetAll(ctx, tenant.Key(ctx, refundKeyPrefix+refundID))
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	refunds := make([]Refund, 0, len(ids))
+	for _, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		refund, err := refundFromHash(cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, nil
+}
+
+func refundFromHash(v map[string]string) (Refund, error) {
+	amount, err := money.FromStringToFloat(v["amount"])
+	if err != nil {
+		return Refund{}, err
+	}
+
+	refund := Refund{
+		ID:            v["id"],
+		CorrelationID: v["correlationId"],
+		Amount:        amount,
+		Processor:     v["processor"],
+		Status:        RefundStatus(v["status"]),
+		Error:         v["error"],
+	}
+	if refund.CreatedAt, err = time.Parse(time.RFC3339Nano, v["createdAt"]); err != nil {
+		return Refund{}, err
+	}
+	if processed := v["processedAt"]; processed != "" {
+		if refund.ProcessedAt, err = time.Parse(time.RFC3339Nano, processed); err != nil {
+			return Refund{}, err
+		}
+	}
+	return refund, nil
 }
 
 func (r *repository) FindProcessorHealth(ctx context.Context, name externalservices.ProcessorName) (externalservices.HealthCheckResponse, error) {
-	v, err := r.rdb.HGetAll(ctx, string(name)).Result()
+	v, err := r.rdb.HGetAll(ctx, tenant.Key(ctx, string(name))).Result()
 	if err != nil {
 		return externalservices.HealthCheckResponse{}, err
 	}
@@ -110,7 +661,7 @@ func (r *repository) FindProcessorHealth(ctx context.Context, name externalservi
 }
 
 func (r *repository) SaveProcessorHealthStatus(ctx context.Context, name externalservices.ProcessorName, health externalservices.HealthCheckResponse) error {
-	err := r.rdb.HSet(ctx, string(name), map[string]any{
+	err := r.rdb.HSet(ctx, tenant.Key(ctx, string(name)), map[string]any{
 		"failing":         health.Failing,
 		"minResponseTime": health.MinResponseTime,
 	}).Err()
@@ -122,21 +673,7 @@ func (r *repository) SaveProcessorHealthStatus(ctx context.Context, name externa
 }
 
 func (r *repository) GetPaymentsSummary(ctx context.Context, params PaymentSummaryParams) (PaymentSummary, error) {
-    var results []string
-	var err error
-    
-	if params.Filter {
-		min := strconv.FormatInt(params.From.UnixNano(), 10)
-		max := strconv.FormatInt(params.To.UnixNano(), 10)
-    
-		results, err = r.rdb.ZRangeByScore(ctx, "payments", &redis.ZRangeBy{
-			Min: min,
-			Max: max,
-		}).Result()
-	} else {
-		results, err = r.rdb.ZRange(ctx, "payments", 0, -1).Result()
-	}
-    
+	results, err := r.paymentsInWindow(ctx, params)
 	if err != nil {
 		return PaymentSummary{}, err
 	}
@@ -151,6 +688,15 @@ func (r *repository) GetPaymentsSummary(ctx context.Context, params PaymentSumma
 			continue
 		}
     
+		// Só o membro do sucesso conta, como no processador. Falhas, estornos e
+		// qualquer status novo ficam de fora; o estorno não abate o valor bruto.
+		if p.Status != PaymentStatusSuccess {
+			continue
+		}
+		if params.MerchantID != "" && p.MerchantID != params.MerchantID {
+			continue
+		}
+    
 		switch p.Processor {
 		case string(externalservices.ProcessorDefault):
 			defaultCount++
@@ -173,3 +719,179 @@ func (r *repository) GetPaymentsSummary(ctx context.Context, params PaymentSumma
 	}, nil
 }
 
+func (r *repository) GetPaymentsSummaryDetails(ctx context.Context, params PaymentSummaryParams) (PaymentSummaryDetails, error) {
+	results, err := r.paymentsInWindow(ctx, params)
+	if err != nil {
+		return PaymentSummaryDetails{}, err
+	}
+
+	return summarizeDetails(filterMerchant(decodeMembers(results), params.MerchantID)), nil
+}
+
+func (r *repository) GetPaymentsTimeSeries(ctx context.Context, params TimeSeriesParams) (PaymentTimeSeries, error) {
+	results, err := r.paymentsInWindow(ctx, PaymentSummaryParams{
+		Filter: true,
+		From:   params.From,
+		To:     params.To,
+	})
+	if err != nil {
+		return PaymentTimeSeries{}, err
+	}
+
+	return bucketize(decodeMembers(results), params), nil
+}
+
+// ScanPayments percorre a janela em páginas de scanPageSize, entregando para fn
+// apenas o registro atual de cada pagamento. O cursor é o último score lido
+// mais o número de membros já vistos com esse score, então a memória usada não
+// depende do tamanho da janela.
+func (r *repository) ScanPayments(ctx context.Context, params PaymentSummaryParams, fn func(page []Payment) error) error {
+	min, max := "-inf", "+inf"
+	if params.Filter {
+		min = strconv.FormatInt(params.From.UnixNano(), 10)
+		max = strconv.FormatInt(params.To.UnixNano(), 10)
+	}
+
+	cursor := math.Inf(-1)
+	var offset int64
+	// seen guarda os pagamentos já entregues com o score do cursor, o único
+	// que pode se repetir na página seguinte.
+	seen := make(map[string]float64)
+
+	for {
+		zs, err := r.rdb.ZRangeByScoreWithScores(ctx, tenant.Key(ctx, paymentsKey), &redis.ZRangeBy{
+			Min:    min,
+			Max:    max,
+			Offset: offset,
+			Count:  scanPageSize,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(zs) == 0 {
+			return nil
+		}
+
+		last := zs[len(zs)-1].Score
+		same := int64(0)
+		for i := len(zs) - 1; i >= 0 && zs[i].Score == last; i-- {
+			same++
+		}
+		if last == cursor {
+			offset += same
+		} else {
+			cursor = last
+			offset = same
+			min = strconv.FormatFloat(last, 'f', -1, 64)
+		}
+
+		page, err := r.currentPayments(ctx, zs, seen)
+		if err != nil {
+			return err
+		}
+		for id, score := range seen {
+			if score != last {
+				delete(seen, id)
+			}
+		}
+		page = filterMerchant(page, params.MerchantID)
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+
+		if len(zs) < scanPageSize {
+			return nil
+		}
+	}
+}
+
+// currentPayments descarta os membros que não correspondem mais ao hash do
+// pagamento (estados antigos gravados por SavePayments anteriores). Só status
+// e timestamp são comparados: o refundedAmount muda no hash sem novo membro.
+// Estornos parciais repetem status e timestamp, então seen evita entregar o
+// mesmo pagamento duas vezes.
+func (r *repository) currentPayments(ctx context.Context, zs []redis.Z, seen map[string]float64) ([]Payment, error) {
+	members := make([]Payment, 0, len(zs))
+	scores := make([]float64, 0, len(zs))
+	for _, z := range zs {
+		s, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		for _, p := range decodeMembers([]string{s}) {
+			members = append(members, p)
+			scores = append(scores, z.Score)
+		}
+	}
+
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(members))
+	for i, p := range members {
+		if p.CorrelationID != "" {
+			cmds[i] = pipe.HGetAll(ctx, tenant.Key(ctx, p.CorrelationID))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	page := members[:0]
+	for i, p := range members {
+		if cmds[i] == nil {
+			page = append(page, p)
+			continue
+		}
+		if _, dup := seen[p.CorrelationID]; dup {
+			continue
+		}
+		current, err := paymentFromHash(p.CorrelationID, cmds[i].Val())
+		if err != nil {
+			continue
+		}
+		if current.Status == p.Status && current.timestamp().Equal(p.timestamp()) {
+			seen[p.CorrelationID] = scores[i]
+			page = append(page, current)
+		}
+	}
+	return page, nil
+}
+
+// decodeMembers converte os membros do sorted set "payments" em Payment.
+// Membros inválidos são ignorados, como no summary.
+func decodeMembers(results []string) []Payment {
+	payments := make([]Payment, 0, len(results))
+	for _, jsonStr := range results {
+		var p Payment
+		if err := json.Unmarshal([]byte(jsonStr), &p); err != nil {
+			continue
+		}
+		// O membro guarda o valor em centavos.
+		p.Amount = money.ToFloat(int(p.Amount))
+		p.RefundedAmount = money.ToFloat(int(p.RefundedAmount))
+		payments = append(payments, p)
+	}
+	return payments
+}
+
+// filterMerchant mantém só os pagamentos do merchant; vazio não filtra.
+func filterMerchant(payments []Payment, merchantID string) []Payment {
+	if merchantID == "" {
+		return payments
+	}
+	return slices.DeleteFunc(payments, func(p Payment) bool {
+		return p.MerchantID != merchantID
+	})
+}
+
+func (r *repository) paymentsInWindow(ctx context.Context, params PaymentSummaryParams) ([]string, error) {
+	if !params.Filter {
+		return r.rdb.ZRange(ctx, tenant.Key(ctx, paymentsKey), 0, -1).Result()
+	}
+
+	return r.rdb.ZRangeByScore(ctx, tenant.Key(ctx, paymentsKey), &redis.ZRangeBy{
+		Min: strconv.FormatInt(params.From.UnixNano(), 10),
+		Max: strconv.FormatInt(params.To.UnixNano(), 10),
+	}).Result()
+}
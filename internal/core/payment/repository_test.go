@@ -12,6 +12,7 @@ import (
 	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
 	"github.com/oprimogus/rinha-backend-2025/internal/core/money"
 	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
 	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
 	"github.com/oprimogus/rinha-backend-2025/internal/infra/testcontainers"
 	"github.com/stretchr/testify/assert"
@@ -48,6 +49,8 @@ func (s *RepositoryTestSuite) SetupSuite() {
     db := database.GetRedis()
     s.db = db
     s.r = payment.NewRepository(db)
+    // Cria as filas em memória usadas por ProcessPayment e pelo scheduler.
+    payment.NewPaymentWorker(s.r, cfg.Payment)
 }
 
 func (s *RepositoryTestSuite) TearDownSuite() {
@@ -163,20 +166,31 @@ func (s *RepositoryTestSuite) TestGetPaymentsSummary_WithFilter() {
 			CorrelationID: uuid.New().String(),
 			Amount:        100.00,
 			Processor:     string(externalservices.ProcessorDefault),
+			Status:        payment.PaymentStatusSuccess,
 			StartedAt:     now.Add(-10 * time.Second),
 		},
 		{
 			CorrelationID: uuid.New().String(),
 			Amount:        200.00,
 			Processor:     string(externalservices.ProcessorFallback),
+			Status:        payment.PaymentStatusSuccess,
 			StartedAt:     now.Add(-5 * time.Second),
 		},
 		{
 			CorrelationID: uuid.New().String(),
 			Amount:        50.00,
 			Processor:     string(externalservices.ProcessorDefault),
+			Status:        payment.PaymentStatusSuccess,
 			StartedAt:     now.Add(-2 * time.Second),
 		},
+		// O processador recusou: não entra no summary.
+		{
+			CorrelationID: uuid.New().String(),
+			Amount:        70.00,
+			Processor:     string(externalservices.ProcessorDefault),
+			Status:        payment.PaymentStatusFailed,
+			StartedAt:     now.Add(-3 * time.Second),
+		},
 	}
 
 	for _, p := range payments {
@@ -209,12 +223,14 @@ func (s *RepositoryTestSuite) TestGetPaymentsSummary_WithoutFilter() {
 			CorrelationID: uuid.New().String(),
 			Amount:        300.00,
 			Processor:     string(externalservices.ProcessorFallback),
+			Status:        payment.PaymentStatusSuccess,
 			StartedAt:     now.Add(-30 * time.Second),
 		},
 		{
 			CorrelationID: uuid.New().String(),
 			Amount:        100.00,
 			Processor:     string(externalservices.ProcessorDefault),
+			Status:        payment.PaymentStatusSuccess,
 			StartedAt:     now.Add(-25 * time.Second),
 		},
 	}
@@ -237,3 +253,321 @@ func (s *RepositoryTestSuite) TestGetPaymentsSummary_WithoutFilter() {
 }
 
 
+
+func (s *RepositoryTestSuite) TestCountPendingPayments() {
+	ctx := context.Background()
+	now := time.Now()
+
+	before, err := s.r.CountPendingPayments(ctx, now)
+	assert.NoError(s.T(), err)
+
+	p := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        42.00,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     now.Add(-time.Second),
+	}
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+
+	pending, err := s.r.CountPendingPayments(ctx, now)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), before+1, pending)
+
+	// Uma falha ainda vai ser reprocessada: o pagamento continua pendente.
+	p.Status = payment.PaymentStatusFailed
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+	pending, err = s.r.CountPendingPayments(ctx, now)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), before+1, pending)
+
+	p.Status = payment.PaymentStatusSuccess
+	p.Processor = string(externalservices.ProcessorDefault)
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+
+	pending, err = s.r.CountPendingPayments(ctx, now)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), before, pending)
+}
+
+func (s *RepositoryTestSuite) TestCreatePayments() {
+	ctx := context.Background()
+	p := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        12.34,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     time.Now(),
+		Description:   "pedido 42",
+	}
+
+	created, err := s.r.CreatePayments(ctx, []payment.Payment{p})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []bool{true}, created)
+
+	// O hash nasce inteiro: o segundo lote não o sobrescreve.
+	dup := p
+	dup.Amount = 99
+	created, err = s.r.CreatePayments(ctx, []payment.Payment{dup})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []bool{false}, created)
+
+	saved, err := s.r.FindPaymentByID(ctx, p.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.InDelta(s.T(), 12.34, saved.Amount, 0.001)
+	assert.Equal(s.T(), "pedido 42", saved.Description)
+	assert.Equal(s.T(), payment.PaymentStatusPending, saved.Status)
+}
+
+func (s *RepositoryTestSuite) TestScanPayments() {
+	ctx := context.Background()
+	// Janela isolada dos outros testes da suíte.
+	base := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(time.Now().UnixNano() % int64(time.Hour)))
+
+	transitions := []payment.PaymentStatus{payment.PaymentStatusSuccess, payment.PaymentStatusDead}
+	for _, final := range transitions {
+		p := payment.Payment{
+			CorrelationID: uuid.New().String(),
+			Amount:        10.00,
+			Status:        payment.PaymentStatusPending,
+			StartedAt:     base,
+		}
+		assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+		p.Status = final
+		assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+	}
+
+	// Estorno reservado ainda sem novo membro, e dois estornos parciais com o
+	// mesmo status e timestamp: cada pagamento sai uma vez.
+	reserved := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        10.00,
+		Status:        payment.PaymentStatusSuccess,
+		StartedAt:     base,
+	}
+	assert.NoError(s.T(), s.r.SavePayment(ctx, reserved))
+	assert.NoError(s.T(), s.r.ReserveRefund(ctx, reserved.CorrelationID, 300))
+
+	refunded := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        10.00,
+		Status:        payment.PaymentStatusPartiallyRefunded,
+		StartedAt:     base,
+	}
+	for _, amount := range []float64{2.00, 4.00} {
+		refunded.RefundedAmount = amount
+		assert.NoError(s.T(), s.r.SavePayment(ctx, refunded))
+	}
+
+	// Mais membros com o mesmo score do que cabem numa página.
+	for range 520 {
+		assert.NoError(s.T(), s.r.SavePayment(ctx, payment.Payment{
+			CorrelationID: uuid.New().String(),
+			Amount:        1.00,
+			Processor:     string(externalservices.ProcessorDefault),
+			Status:        payment.PaymentStatusSuccess,
+			StartedAt:     base.Add(time.Second),
+		}))
+	}
+
+	seen := make(map[string]payment.PaymentStatus)
+	pages := 0
+	err := s.r.ScanPayments(ctx, payment.PaymentSummaryParams{
+		Filter: true,
+		From:   base,
+		To:     base.Add(time.Second),
+	}, func(page []payment.Payment) error {
+		pages++
+		for _, p := range page {
+			_, dup := seen[p.CorrelationID]
+			assert.False(s.T(), dup, "payment exported twice")
+			seen[p.CorrelationID] = p.Status
+		}
+		return nil
+	})
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), seen, 524)
+	assert.Greater(s.T(), pages, 1)
+	for _, status := range seen {
+		assert.NotEqual(s.T(), payment.PaymentStatusPending, status)
+	}
+}
+
+func (s *RepositoryTestSuite) TestListPayments() {
+	ctx := context.Background()
+	base := time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(time.Now().UnixNano() % int64(time.Hour)))
+
+	var defaultIDs []string
+	for i := range 5 {
+		p := payment.Payment{
+			CorrelationID: uuid.New().String(),
+			Amount:        float64(i + 1),
+			Status:        payment.PaymentStatusPending,
+			StartedAt:     base.Add(time.Duration(i) * time.Second),
+		}
+		assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+
+		p.Status = payment.PaymentStatusSuccess
+		p.Processor = string(externalservices.ProcessorFallback)
+		if i%2 == 0 {
+			p.Processor = string(externalservices.ProcessorDefault)
+			defaultIDs = append(defaultIDs, p.CorrelationID)
+		}
+		assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+	}
+
+	params := payment.ListPaymentsParams{
+		Status:    payment.PaymentStatusSuccess,
+		Processor: string(externalservices.ProcessorDefault),
+		From:      base,
+		To:        base.Add(5 * time.Second),
+		Limit:     2,
+	}
+
+	var listed []string
+	for {
+		page, err := s.r.ListPayments(ctx, params)
+		assert.NoError(s.T(), err)
+		for _, p := range page.Items {
+			listed = append(listed, p.CorrelationID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+	}
+	assert.Equal(s.T(), defaultIDs, listed)
+
+	pending, err := s.r.ListPayments(ctx, payment.ListPaymentsParams{
+		Status: payment.PaymentStatusPending,
+		From:   base,
+		To:     base.Add(5 * time.Second),
+		Limit:  10,
+	})
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), pending.Items)
+}
+
+func (s *RepositoryTestSuite) TestListPayments_StableAcrossTransitions() {
+	ctx := context.Background()
+	base := time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(time.Now().UnixNano() % int64(time.Hour)))
+
+	// Pagamentos ainda pendentes misturados com pagamentos já enviados, cujo
+	// requestedAt fica depois do startedAt de todos os outros.
+	payments := make([]payment.Payment, 4)
+	var ids []string
+	for i := range payments {
+		payments[i] = payment.Payment{
+			CorrelationID: uuid.New().String(),
+			Amount:        1.00,
+			Status:        payment.PaymentStatusPending,
+			StartedAt:     base.Add(time.Duration(i) * time.Second),
+		}
+		if i%2 == 1 {
+			payments[i].Status = payment.PaymentStatusSuccess
+			payments[i].Processor = string(externalservices.ProcessorDefault)
+			payments[i].RequestedAt = base.Add(time.Minute)
+		}
+		assert.NoError(s.T(), s.r.SavePayment(ctx, payments[i]))
+		ids = append(ids, payments[i].CorrelationID)
+	}
+
+	params := payment.ListPaymentsParams{From: base, To: base.Add(time.Hour), Limit: 2}
+	var listed []string
+	for {
+		page, err := s.r.ListPayments(ctx, params)
+		assert.NoError(s.T(), err)
+		for _, p := range page.Items {
+			listed = append(listed, p.CorrelationID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.NextCursor
+
+		// O primeiro pagamento é enviado entre uma página e outra e não pode
+		// reaparecer depois do cursor.
+		if payments[0].Status == payment.PaymentStatusPending {
+			payments[0].Status = payment.PaymentStatusSuccess
+			payments[0].Processor = string(externalservices.ProcessorDefault)
+			payments[0].RequestedAt = base.Add(2 * time.Minute)
+			assert.NoError(s.T(), s.r.SavePayment(ctx, payments[0]))
+		}
+	}
+	assert.Equal(s.T(), ids, listed)
+}
+
+func (s *RepositoryTestSuite) TestSavePayment_PublishesOnStatusChange() {
+	ctx := context.Background()
+	p := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        5.00,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     time.Now(),
+	}
+
+	events := func() []payment.PaymentStatus {
+		msgs, err := s.db.XRange(ctx, payment.EventsStream, "-", "+").Result()
+		assert.NoError(s.T(), err)
+		var statuses []payment.PaymentStatus
+		for _, msg := range msgs {
+			e, err := payment.DecodeStatusEvent(msg)
+			if err == nil && e.Payment.CorrelationID == p.CorrelationID {
+				statuses = append(statuses, e.Payment.Status)
+			}
+		}
+		return statuses
+	}
+
+	created, err := s.r.CreatePayments(ctx, []payment.Payment{p})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []bool{true}, created)
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+
+	p.Status = payment.PaymentStatusSuccess
+	p.Processor = string(externalservices.ProcessorDefault)
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+
+	assert.Equal(s.T(), []payment.PaymentStatus{payment.PaymentStatusPending, payment.PaymentStatusSuccess}, events())
+}
+
+func (s *RepositoryTestSuite) TestTenantIsolation() {
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	now := time.Now()
+	p := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        42.00,
+		Processor:     string(externalservices.ProcessorDefault),
+		Status:        payment.PaymentStatusSuccess,
+		StartedAt:     now,
+		TenantID:      "acme",
+	}
+	assert.NoError(s.T(), s.r.SavePayment(acme, p))
+
+	saved, err := s.r.FindPaymentByID(acme, p.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "acme", saved.TenantID)
+
+	_, err = s.r.FindPaymentByID(globex, p.CorrelationID)
+	assert.ErrorIs(s.T(), err, payment.ErrPaymentNotFound)
+	_, err = s.r.FindPaymentByID(context.Background(), p.CorrelationID)
+	assert.ErrorIs(s.T(), err, payment.ErrPaymentNotFound)
+
+	window := payment.PaymentSummaryParams{Filter: true, From: now.Add(-time.Second), To: now.Add(time.Second)}
+
+	summary, err := s.r.GetPaymentsSummary(acme, window)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, summary.Default.TotalRequests)
+
+	summary, err = s.r.GetPaymentsSummary(globex, window)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 0, summary.Default.TotalRequests)
+
+	// Um id com o prefixo de outro tenant nunca chega ao Redis, nem no POST
+	// nem nas rotas /payments/{id}.
+	forged := payment.PaymentParams{CorrelationID: "tenant:acme:" + uuid.New().String(), Amount: 1}
+	assert.ErrorIs(s.T(), forged.Validate(), payment.ErrInvalidCorrelationID)
+	_, err = payment.NewService(s.r).FindPayment(context.Background(), "tenant:acme:"+p.CorrelationID)
+	assert.ErrorIs(s.T(), err, payment.ErrPaymentNotFound)
+}
@@ -2,68 +2,205 @@ package payment
 
 import (
 	"context"
-	"log/slog"
+	"errors"
 	"time"
 
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
 	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
 )
 
+var serviceLog = logger.Component("service")
+
+const summaryWaitPollInterval = 20 * time.Millisecond
+
 type Service struct {
-	r                 Repository
-	defaultProcessor  externalservices.PaymentProcessor
-	fallbackProcessor externalservices.PaymentProcessor
+	r               Repository
+	processors      *externalservices.Registry
+	timestampPolicy TimestampPolicy
+	cfg             config.Payment
 }
 
 func NewService(r Repository) *Service {
+	cfg := config.GetInstance()
+
+	policy, err := ParseTimestampPolicy(cfg.Payment.TimestampPolicy)
+	if err != nil {
+		serviceLog.Warn("invalid timestamp policy, using receipt", "error", err)
+		policy = TimestampAtReceipt
+	}
+
 	return &Service{
-		r:                 r,
-		defaultProcessor:  externalservices.NewDefaultPaymentProcessor(),
-		fallbackProcessor: externalservices.NewFallbackPaymentProcessor(),
+		r:               r,
+		processors:      externalservices.NewRegistry(cfg),
+		timestampPolicy: policy,
+		cfg:             cfg.Payment,
+	}
+}
+
+// Tenants devolve os tenants com processadores cadastrados.
+func (s *Service) Tenants() []string {
+	return s.processors.Tenants()
+}
+
+// processorsFor devolve os processadores do tenant do contexto.
+func (s *Service) processorsFor(ctx context.Context) (externalservices.Processors, error) {
+	p, ok := s.processors.Processors(tenant.FromContext(ctx))
+	if !ok {
+		return externalservices.Processors{}, tenant.ErrUnknownTenant
 	}
+	return p, nil
+}
+
+// requestedAt devolve o instante enviado ao processador. O valor é truncado em
+// milissegundos porque é a precisão que o processador guarda; assim o score
+// salvo no Redis é exatamente o mesmo instante que o processador usa no summary.
+func (s *Service) requestedAt(p Payment) time.Time {
+	if s.timestampPolicy == TimestampAtDispatch {
+		return time.Now().UTC().Truncate(time.Millisecond)
+	}
+	// Um pagamento agendado é "recebido" no horário pedido pelo cliente.
+	if !p.ExecuteAt.IsZero() {
+		return p.ExecuteAt.UTC().Truncate(time.Millisecond)
+	}
+	return p.StartedAt.UTC().Truncate(time.Millisecond)
+}
+
+// FindProcessorHealth devolve o último health check salvo do processador do
+// tenant do contexto, sem chamar o processador.
+func (s *Service) FindProcessorHealth(ctx context.Context, name externalservices.ProcessorName) (externalservices.HealthCheckResponse, error) {
+	return s.r.FindProcessorHealth(ctx, name)
 }
 
 func (s *Service) GetHealthStatus(ctx context.Context, name externalservices.ProcessorName) (externalservices.HealthCheckResponse, error) {
-	p := externalservices.FindPaymentProcessorStrategy(name)
+	processors, err := s.processorsFor(ctx)
+	if err != nil {
+		return externalservices.HealthCheckResponse{}, err
+	}
+	p := processors.ByName(name)
 
 	h, err := p.VerifyHealth()
 	if err != nil {
-		slog.Info("fail on get health check status of default processor", "error", err)
+		serviceLog.Info("fail on get health check status of default processor", "error", err)
 		return externalservices.HealthCheckResponse{}, err
 	}
 	err = s.r.SaveProcessorHealthStatus(ctx, p.ProcessorName(), h)
 	if err != nil {
-		slog.Info("fail on save health check status of default processor", "error", err)
+		serviceLog.Info("fail on save health check status of default processor", "error", err)
 		return externalservices.HealthCheckResponse{}, err
 	}
 	return h, nil
 }
 
+// GetProcessorSummary consulta o summary que o próprio processador mantém.
+func (s *Service) GetProcessorSummary(ctx context.Context, name externalservices.ProcessorName, params PaymentSummaryParams) (externalservices.AdminSummaryResponse, error) {
+	processors, err := s.processorsFor(ctx)
+	if err != nil {
+		return externalservices.AdminSummaryResponse{}, err
+	}
+	return processors.ByName(name).PaymentsSummary(ctx, params.From, params.To)
+}
+
 func (s *Service) GetPaymentsSummary(ctx context.Context, params PaymentSummaryParams) (PaymentSummary, error) {
-	return s.r.GetPaymentsSummary(ctx, params)
+	if !params.Watermark {
+		return s.r.GetPaymentsSummary(ctx, params)
+	}
+
+	var until time.Time
+	if params.Filter {
+		until = params.To
+	}
+
+	pending, err := s.waitPendingPayments(ctx, until, min(params.Wait, s.cfg.MaxSummaryWait))
+	if err != nil {
+		return PaymentSummary{}, err
+	}
+	asOf := time.Now().UTC()
+
+	summary, err := s.r.GetPaymentsSummary(ctx, params)
+	if err != nil {
+		return PaymentSummary{}, err
+	}
+	summary.AsOf = asOf
+	summary.PendingCount = &pending
+	return summary, nil
 }
 
-func (s *Service) ProcessPayment(ctx context.Context, params PaymentParams) (Payment, error) {
-	payment := Payment{
-		CorrelationID: params.CorrelationID,
-		Amount:        params.Amount,
-		Status:        PaymentStatusPending,
-		StartedAt:     time.Now(),
+func (s *Service) GetPaymentsSummaryDetails(ctx context.Context, params PaymentSummaryParams) (PaymentSummaryDetails, error) {
+	details, err := s.r.GetPaymentsSummaryDetails(ctx, params)
+	if err != nil {
+		return PaymentSummaryDetails{}, err
+	}
+
+	processors, err := s.processorsFor(ctx)
+	if err != nil {
+		return PaymentSummaryDetails{}, err
+	}
+	details.Default.EstimatedFee = roundCents(details.Default.TotalAmount * processors.Default.FeeRate())
+	details.Fallback.EstimatedFee = roundCents(details.Fallback.TotalAmount * processors.Fallback.FeeRate())
+	return details, nil
+}
+
+func (s *Service) GetPaymentsTimeSeries(ctx context.Context, params TimeSeriesParams) (PaymentTimeSeries, error) {
+	return s.r.GetPaymentsTimeSeries(ctx, params)
+}
+
+func (s *Service) FindPayment(ctx context.Context, id string) (Payment, error) {
+	if !validCorrelationID(id) {
+		return Payment{}, ErrPaymentNotFound
 	}
+	return s.r.FindPaymentByID(ctx, id)
+}
+
+func (s *Service) ListPayments(ctx context.Context, params ListPaymentsParams) (PaymentPage, error) {
+	return s.r.ListPayments(ctx, params)
+}
+
+// waitPendingPayments espera, no máximo por wait, até que não existam mais
+// pagamentos em voo com startedAt <= until e devolve quantos ainda restam.
+func (s *Service) waitPendingPayments(ctx context.Context, until time.Time, wait time.Duration) (int64, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		pending, err := s.r.CountPendingPayments(ctx, until)
+		if err != nil {
+			return 0, err
+		}
+		if pending == 0 || !time.Now().Before(deadline) {
+			return pending, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return pending, nil
+		case <-time.After(summaryWaitPollInterval):
+		}
+	}
+}
+
+func (s *Service) ProcessPayment(ctx context.Context, params PaymentParams) (Payment, error) {
+	payment := params.toPayment()
+	payment.TenantID = tenant.FromContext(ctx)
 
 	// Salva o pagamento primeiro
 	err := s.r.SavePayment(ctx, payment)
 	if err != nil {
-		slog.Error("fail on save payment", "error", err, "payload", payment)
+		serviceLog.Error("fail on save payment", "error", err, "payload", payment)
 		return Payment{}, err
 	}
 
-	if !s.sendToQueueWithRetry(ctx, payment, 3) {
+	// Pagamentos agendados entram na fila pelo scheduler.
+	if payment.Status == PaymentStatusScheduled {
+		return payment, nil
+	}
+
+	if !s.sendToQueueWithRetry(ctx, payment, s.cfg.EnqueueRetries) {
 		go func() {
-			slog.Error("failed to queue payment after retries", "payment", payment)
+			serviceLog.Error("failed to queue payment after retries", "payment", payment)
 
-			payment.Status = PaymentStatusFailed
-			if updateErr := s.r.SavePayment(ctx, payment); updateErr != nil {
-				slog.Error("failed to update payment status", "error", updateErr, "payment", payment)
+			payment.Status = PaymentStatusDead
+			if updateErr := s.r.SavePayment(context.WithoutCancel(ctx), payment); updateErr != nil {
+				serviceLog.Error("failed to update payment status", "error", updateErr, "payment", payment)
 			}
 		}()
 
@@ -73,6 +210,38 @@ func (s *Service) ProcessPayment(ctx context.Context, params PaymentParams) (Pay
 	return payment, nil
 }
 
+// DispatchScheduledPayments coloca na fila os pagamentos agendados que já
+// venceram e devolve quantos foram enfileirados. O pagamento só sai do
+// scheduler depois de enfileirado; com a fila cheia ele volta quando o lease
+// expirar.
+func (s *Service) DispatchScheduledPayments(ctx context.Context) (int, error) {
+	ids, err := s.r.ClaimDueScheduledPayments(ctx, time.Now(), s.cfg.ScheduleLease, int64(s.cfg.ScheduleBatch))
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for _, id := range ids {
+		p, err := s.r.FindPaymentByID(ctx, id)
+		if err != nil && !errors.Is(err, ErrPaymentNotFound) {
+			serviceLog.Error("fail on find scheduled payment", "error", err, "correlation_id", id)
+			continue
+		}
+		// Cancelado, já processado ou removido: só tira do scheduler.
+		if err == nil && p.Status == PaymentStatusScheduled {
+			if !SendToQueue(p) {
+				serviceLog.Warn("payment queue is full, scheduled payment will be retried", "correlation_id", id)
+				continue
+			}
+			dispatched++
+		}
+		if err := s.r.UnschedulePayment(ctx, id); err != nil {
+			serviceLog.Error("fail on unschedule payment", "error", err, "correlation_id", id)
+		}
+	}
+	return dispatched, nil
+}
+
 func (s *Service) sendToQueueWithRetry(ctx context.Context, payment Payment, maxRetries int) bool {
 	for i := range maxRetries {
 		if SendToQueue(payment) {
@@ -90,65 +259,92 @@ func (s *Service) sendToQueueWithRetry(ctx context.Context, payment Payment, max
 }
 
 func (s *Service) ProcessPaymentAsync(ctx context.Context, p Payment) error {
+	// O pagamento vem da fila, fora da requisição que definiu o tenant.
+	ctx = tenant.WithTenant(ctx, p.TenantID)
+	processors, err := s.processorsFor(ctx)
+	if err != nil {
+		return err
+	}
+
 	var hDefault, hFallback externalservices.HealthCheckResponse
 	var hDefaultErr, hFallbackErr error
 
-	hDefault, hDefaultErr = s.r.FindProcessorHealth(ctx, s.defaultProcessor.ProcessorName())
-	hFallback, hFallbackErr = s.r.FindProcessorHealth(ctx, s.fallbackProcessor.ProcessorName())
+	hDefault, hDefaultErr = s.r.FindProcessorHealth(ctx, processors.Default.ProcessorName())
+	hFallback, hFallbackErr = s.r.FindProcessorHealth(ctx, processors.Fallback.ProcessorName())
 
 	if hDefaultErr != nil && hFallbackErr != nil {
-		slog.Error("processors are down")
+		serviceLog.Error("processors are down")
 		return ErrAllProcessorsAreDown
 	}
 	if hDefaultErr != nil {
-		slog.Error("fail on get health check status of default processor", "error", hDefaultErr)
-		return s.processPaymentWithFallback(ctx, p)
+		serviceLog.Error("fail on get health check status of default processor", "error", hDefaultErr)
+		return s.processPaymentWithFallback(ctx, p, processors.Fallback)
 	}
 	if hFallbackErr != nil {
-		slog.Error("fail on get health check status of fallback processor", "error", hFallbackErr)
-		return s.processPaymentWithDefault(ctx, p)
+		serviceLog.Error("fail on get health check status of fallback processor", "error", hFallbackErr)
+		return s.processPaymentWithDefault(ctx, p, processors.Default)
 	}
 
 	if hDefault.Failing && hFallback.Failing {
 		return ErrAllProcessorsAreDown
 	}
 	if hDefault.Failing {
-		return s.processPaymentWithFallback(ctx, p)
+		return s.processPaymentWithFallback(ctx, p, processors.Fallback)
 	}
 	if hFallback.Failing {
-		return s.processPaymentWithDefault(ctx, p)
+		return s.processPaymentWithDefault(ctx, p, processors.Default)
 	}
 	if !hDefault.Failing && !hFallback.Failing {
-		if hDefault.MinResponseTime > 5*1000 {
-			return s.processPaymentWithFallback(ctx, p)
+		// Lido a cada pagamento: o limiar muda com o hot reload.
+		if time.Duration(hDefault.MinResponseTime)*time.Millisecond > config.GetInstance().Payment.SlowProcessorThreshold {
+			return s.processPaymentWithFallback(ctx, p, processors.Fallback)
 		}
-		return s.processPaymentWithDefault(ctx, p)
+		return s.processPaymentWithDefault(ctx, p, processors.Default)
 	}
 	return nil
 }
 
-func (s *Service) processPaymentWithDefault(ctx context.Context, p Payment) error {
-	resp, err := s.defaultProcessor.ProcessPayment(ctx, externalservices.PaymentParams{
+// claimDispatch reivindica o pagamento logo antes da chamada ao processador:
+// enquanto nenhum processador foi escolhido, ele continua cancelável.
+func (s *Service) claimDispatch(ctx context.Context, p Payment) (bool, error) {
+	claimed, err := s.r.ClaimPaymentDispatch(ctx, p.CorrelationID)
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		serviceLog.Info("payment was cancelled, skipping dispatch", "correlation_id", p.CorrelationID)
+	}
+	return claimed, nil
+}
+
+func (s *Service) processPaymentWithDefault(ctx context.Context, p Payment, processor externalservices.PaymentProcessor) error {
+	if claimed, err := s.claimDispatch(ctx, p); !claimed {
+		return err
+	}
+	p.RequestedAt = s.requestedAt(p)
+	start := time.Now()
+	resp, err := processor.ProcessPayment(ctx, externalservices.PaymentParams{
 		CorrelationID: p.CorrelationID,
 		Amount:        p.Amount,
-		RequestedAt:   p.StartedAt,
+		RequestedAt:   p.RequestedAt,
 	})
+	p.LatencyMs = time.Since(start).Milliseconds()
 
-	p.Processor = string(s.defaultProcessor.ProcessorName())
+	p.Processor = string(processor.ProcessorName())
 	if err != nil {
 		p.Status = PaymentStatusFailed
-		slog.Error("failed to process payment with default processor",
+		serviceLog.Error("failed to process payment with default processor",
 			"error", err,
 			"correlation_id", p.CorrelationID,
 			"response", resp,
 		)
 	} else {
 		p.Status = PaymentStatusSuccess
-		slog.Info("payment processed with default processor", "correlation_id", p.CorrelationID)
+		serviceLog.Info("payment processed with default processor", "correlation_id", p.CorrelationID)
 	}
 
 	if saveErr := s.r.SavePayment(ctx, p); saveErr != nil {
-		slog.Error("failed to save payment",
+		serviceLog.Error("failed to save payment",
 			"error", saveErr,
 			"correlation_id", p.CorrelationID,
 			"status", p.Status,
@@ -159,17 +355,23 @@ func (s *Service) processPaymentWithDefault(ctx context.Context, p Payment) erro
 	return err
 }
 
-func (s *Service) processPaymentWithFallback(ctx context.Context, p Payment) error {
-	resp, err := s.fallbackProcessor.ProcessPayment(ctx, externalservices.PaymentParams{
+func (s *Service) processPaymentWithFallback(ctx context.Context, p Payment, processor externalservices.PaymentProcessor) error {
+	if claimed, err := s.claimDispatch(ctx, p); !claimed {
+		return err
+	}
+	p.RequestedAt = s.requestedAt(p)
+	start := time.Now()
+	resp, err := processor.ProcessPayment(ctx, externalservices.PaymentParams{
 		CorrelationID: p.CorrelationID,
 		Amount:        p.Amount,
-		RequestedAt:   p.StartedAt,
+		RequestedAt:   p.RequestedAt,
 	})
+	p.LatencyMs = time.Since(start).Milliseconds()
 
-	p.Processor = string(s.fallbackProcessor.ProcessorName())
+	p.Processor = string(processor.ProcessorName())
 
 	if err != nil {
-		slog.Error("failed to process payment with fallback processor",
+		serviceLog.Error("failed to process payment with fallback processor",
 			"error", err,
 			"correlation_id", p.CorrelationID,
 			"response", resp,
@@ -178,10 +380,10 @@ func (s *Service) processPaymentWithFallback(ctx context.Context, p Payment) err
 	}
 
 	p.Status = PaymentStatusSuccess
-	slog.Info("payment processed with fallback processor", "correlation_id", p.CorrelationID)
+	serviceLog.Info("payment processed with fallback processor", "correlation_id", p.CorrelationID)
 
 	if saveErr := s.r.SavePayment(ctx, p); saveErr != nil {
-		slog.Error("failed to save payment after fallback processing",
+		serviceLog.Error("failed to save payment after fallback processing",
 			"error", saveErr,
 			"correlation_id", p.CorrelationID,
 			"status", p.Status,
@@ -0,0 +1,406 @@
+package payment_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProcessor imita o payment processor: guarda o requestedAt recebido e
+// calcula o summary da mesma forma que o /admin/payments-summary.
+type fakeProcessor struct {
+	mu       sync.Mutex
+	received map[string]time.Time
+}
+
+func newFakeProcessor() (*fakeProcessor, *httptest.Server) {
+	f := &fakeProcessor{received: make(map[string]time.Time)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/refunds") {
+			json.NewEncoder(w).Encode(externalservices.RefundResponse{Message: "refund processed successfully"})
+			return
+		}
+		var params externalservices.PaymentParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.received[params.CorrelationID] = params.RequestedAt
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(externalservices.PaymentResponse{Message: "payment processed successfully"})
+	}))
+	return f, srv
+}
+
+func (f *fakeProcessor) requestedAt(id string) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received[id]
+}
+
+func (f *fakeProcessor) summary(from, to time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, t := range f.received {
+		if !t.Before(from) && !t.After(to) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *RepositoryTestSuite) newServiceWithFakeProcessor(policy payment.TimestampPolicy) (*payment.Service, *fakeProcessor, func()) {
+	ctx := context.Background()
+	fake, srv := newFakeProcessor()
+
+	cfg := config.GetInstance()
+	cfg.ExternalServices.DefaultPaymentProcessor.BaseURL = srv.URL
+	cfg.ExternalServices.FallbackPaymentProcessor.BaseURL = srv.URL
+	cfg.ExternalServices.DefaultPaymentProcessor.Refunds = true
+	cfg.Payment.TimestampPolicy = string(policy)
+
+	healthy := externalservices.HealthCheckResponse{Failing: false, MinResponseTime: 0}
+	assert.NoError(s.T(), s.r.SaveProcessorHealthStatus(ctx, externalservices.ProcessorDefault, healthy))
+	assert.NoError(s.T(), s.r.SaveProcessorHealthStatus(ctx, externalservices.ProcessorFallback, healthy))
+
+	return payment.NewService(s.r), fake, srv.Close
+}
+
+func (s *RepositoryTestSuite) TestProcessPaymentAsync_PersistsRequestedAtSentToProcessor() {
+	for _, policy := range []payment.TimestampPolicy{payment.TimestampAtReceipt, payment.TimestampAtDispatch} {
+		s.Run(string(policy), func() {
+			ctx := context.Background()
+			svc, fake, closeFn := s.newServiceWithFakeProcessor(policy)
+			defer closeFn()
+
+			p := payment.Payment{
+				CorrelationID: uuid.New().String(),
+				Amount:        19.90,
+				Status:        payment.PaymentStatusPending,
+				StartedAt:     time.Now().Add(-time.Second),
+			}
+			assert.NoError(s.T(), svc.ProcessPaymentAsync(ctx, p))
+
+			saved, err := s.r.FindPaymentByID(ctx, p.CorrelationID)
+			assert.NoError(s.T(), err)
+			assert.True(s.T(), fake.requestedAt(p.CorrelationID).Equal(saved.RequestedAt))
+
+			if policy == payment.TimestampAtReceipt {
+				assert.True(s.T(), saved.RequestedAt.Equal(p.StartedAt.Truncate(time.Millisecond)))
+			} else {
+				assert.True(s.T(), saved.RequestedAt.After(p.StartedAt))
+			}
+		})
+	}
+}
+
+func (s *RepositoryTestSuite) TestGetPaymentsSummary_WindowMatchesProcessor() {
+	ctx := context.Background()
+	svc, fake, closeFn := s.newServiceWithFakeProcessor(payment.TimestampAtDispatch)
+	defer closeFn()
+
+	var sent []time.Time
+	for range 4 {
+		p := payment.Payment{
+			CorrelationID: uuid.New().String(),
+			Amount:        10.00,
+			Status:        payment.PaymentStatusPending,
+			StartedAt:     time.Now(),
+		}
+		assert.NoError(s.T(), svc.ProcessPaymentAsync(ctx, p))
+		sent = append(sent, fake.requestedAt(p.CorrelationID))
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// As janelas começam em sent[1] para não contar pagamentos de outros testes.
+	windows := []struct {
+		name     string
+		from, to time.Time
+	}{
+		{"inclusive bounds", sent[1], sent[2]},
+		{"single instant", sent[2], sent[2]},
+		{"up to last", sent[1], sent[3]},
+		{"inner window", sent[1].Add(time.Millisecond), sent[3].Add(-time.Millisecond)},
+	}
+
+	for _, w := range windows {
+		s.Run(w.name, func() {
+			summary, err := s.r.GetPaymentsSummary(ctx, payment.PaymentSummaryParams{
+				Filter: true,
+				From:   w.from,
+				To:     w.to,
+			})
+			assert.NoError(s.T(), err)
+			assert.Equal(s.T(), fake.summary(w.from, w.to), summary.Default.TotalRequests+summary.Fallback.TotalRequests)
+		})
+	}
+}
+
+func (s *RepositoryTestSuite) TestProcessPaymentBatch() {
+	ctx := context.Background()
+	svc := payment.NewService(s.r)
+
+	existing := uuid.New().String()
+	assert.NoError(s.T(), s.r.SavePayment(ctx, payment.Payment{
+		CorrelationID: existing,
+		Amount:        1.00,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     time.Now(),
+	}))
+
+	fresh := uuid.New().String()
+	body := `[
+		{"correlationId": "` + fresh + `", "amount": 10.5},
+		{"correlationId": "` + fresh + `", "amount": 10.5},
+		{"correlationId": "` + existing + `", "amount": 1},
+		{"correlationId": "` + uuid.New().String() + `", "amount": -1}
+	]`
+
+	result, err := svc.ProcessPaymentBatch(ctx, "", func(fn func(payment.BatchItem) error) error {
+		return payment.DecodeBatchArray(strings.NewReader(body), fn)
+	})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, result.Accepted)
+	assert.Equal(s.T(), 2, result.Duplicate)
+	assert.Equal(s.T(), 1, result.Invalid)
+
+	statuses := make([]payment.BatchItemStatus, 0, len(result.Results))
+	for _, item := range result.Results {
+		statuses = append(statuses, item.Status)
+	}
+	assert.Equal(s.T(), []payment.BatchItemStatus{
+		payment.BatchItemAccepted,
+		payment.BatchItemDuplicate,
+		payment.BatchItemDuplicate,
+		payment.BatchItemInvalid,
+	}, statuses)
+
+	saved, err := s.r.FindPaymentByID(ctx, fresh)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.PaymentStatusPending, saved.Status)
+	assert.InDelta(s.T(), 10.5, saved.Amount, 0.001)
+}
+
+func (s *RepositoryTestSuite) TestProcessPaymentBatch_TooLarge() {
+	ctx := context.Background()
+	svc := payment.NewService(s.r)
+
+	first := uuid.New().String()
+	var body strings.Builder
+	body.WriteString(`{"correlationId": "` + first + `", "amount": 1}` + "\n")
+	for range 10000 {
+		body.WriteString(`{"correlationId": "` + uuid.New().String() + `", "amount": 1}` + "\n")
+	}
+
+	result, err := svc.ProcessPaymentBatch(ctx, "", func(fn func(payment.BatchItem) error) error {
+		return payment.DecodeBatchNDJSON(strings.NewReader(body.String()), fn)
+	})
+	assert.ErrorIs(s.T(), err, payment.ErrBatchTooLarge)
+	assert.Empty(s.T(), result.Results)
+
+	// O lote é recusado inteiro, inclusive os itens antes do limite.
+	_, err = s.r.FindPaymentByID(ctx, first)
+	assert.ErrorIs(s.T(), err, payment.ErrPaymentNotFound)
+}
+
+func (s *RepositoryTestSuite) TestCancelPayment() {
+	ctx := context.Background()
+	svc, fake, closeFn := s.newServiceWithFakeProcessor(payment.TimestampAtReceipt)
+	defer closeFn()
+
+	p := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        12.00,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     time.Now(),
+	}
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+
+	cancelled, err := svc.CancelPayment(ctx, p.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.PaymentStatusCancelled, cancelled.Status)
+
+	// O worker descarta o pagamento cancelado sem chamar o processador.
+	assert.NoError(s.T(), svc.ProcessPaymentAsync(ctx, p))
+	assert.True(s.T(), fake.requestedAt(p.CorrelationID).IsZero())
+
+	_, err = svc.CancelPayment(ctx, p.CorrelationID)
+	assert.ErrorIs(s.T(), err, payment.ErrPaymentNotCancellable)
+
+	_, err = svc.CancelPayment(ctx, uuid.New().String())
+	assert.ErrorIs(s.T(), err, payment.ErrPaymentNotFound)
+
+	// Sem processador disponível o pagamento não foi enviado e segue cancelável.
+	failing := externalservices.HealthCheckResponse{Failing: true}
+	assert.NoError(s.T(), s.r.SaveProcessorHealthStatus(ctx, externalservices.ProcessorDefault, failing))
+	assert.NoError(s.T(), s.r.SaveProcessorHealthStatus(ctx, externalservices.ProcessorFallback, failing))
+	undelivered := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        12.00,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     time.Now(),
+	}
+	assert.NoError(s.T(), s.r.SavePayment(ctx, undelivered))
+	assert.ErrorIs(s.T(), svc.ProcessPaymentAsync(ctx, undelivered), payment.ErrAllProcessorsAreDown)
+
+	cancelled, err = svc.CancelPayment(ctx, undelivered.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.PaymentStatusCancelled, cancelled.Status)
+}
+
+func (s *RepositoryTestSuite) TestRefundPayment() {
+	ctx := context.Background()
+	svc, _, closeFn := s.newServiceWithFakeProcessor(payment.TimestampAtReceipt)
+	defer closeFn()
+
+	p := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        100.00,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     time.Now(),
+	}
+	assert.NoError(s.T(), s.r.SavePayment(ctx, p))
+
+	_, err := svc.RefundPayment(ctx, p.CorrelationID, payment.RefundParams{})
+	assert.ErrorIs(s.T(), err, payment.ErrPaymentNotRefundable)
+
+	assert.NoError(s.T(), svc.ProcessPaymentAsync(ctx, p))
+
+	partial := 30.0
+	refund, err := svc.RefundPayment(ctx, p.CorrelationID, payment.RefundParams{Amount: &partial})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.RefundStatusSucceeded, refund.Status)
+
+	saved, err := s.r.FindPaymentByID(ctx, p.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.PaymentStatusPartiallyRefunded, saved.Status)
+	assert.InDelta(s.T(), 30.0, saved.RefundedAmount, 0.001)
+
+	tooMuch := 70.01
+	_, err = svc.RefundPayment(ctx, p.CorrelationID, payment.RefundParams{Amount: &tooMuch})
+	assert.ErrorIs(s.T(), err, payment.ErrRefundExceedsAmount)
+
+	// Sem amount estorna o saldo restante.
+	refund, err = svc.RefundPayment(ctx, p.CorrelationID, payment.RefundParams{})
+	assert.NoError(s.T(), err)
+	assert.InDelta(s.T(), 70.0, refund.Amount, 0.001)
+
+	saved, err = s.r.FindPaymentByID(ctx, p.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.PaymentStatusRefunded, saved.Status)
+
+	refunds, err := svc.ListRefunds(ctx, p.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), refunds, 2)
+
+	// Pagamento no fallback, que não tem estorno configurado.
+	fallback := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        10.00,
+		Processor:     string(externalservices.ProcessorFallback),
+		Status:        payment.PaymentStatusSuccess,
+		StartedAt:     time.Now(),
+	}
+	assert.NoError(s.T(), s.r.SavePayment(ctx, fallback))
+	_, err = svc.RefundPayment(ctx, fallback.CorrelationID, payment.RefundParams{})
+	assert.ErrorIs(s.T(), err, payment.ErrRefundNotSupported)
+
+	details, err := svc.GetPaymentsSummaryDetails(ctx, payment.PaymentSummaryParams{
+		Filter: true,
+		From:   saved.RequestedAt,
+		To:     saved.RequestedAt,
+	})
+	assert.NoError(s.T(), err)
+	assert.InDelta(s.T(), 100.0, details.Default.TotalAmount, 0.001)
+	assert.InDelta(s.T(), 100.0, details.Default.RefundedAmount, 0.001)
+	assert.InDelta(s.T(), 0.0, details.Default.NetAmount, 0.001)
+}
+
+func (s *RepositoryTestSuite) TestScheduledPayments() {
+	ctx := context.Background()
+	svc := payment.NewService(s.r)
+
+	due, err := svc.ProcessPayment(ctx, payment.PaymentParams{
+		CorrelationID: uuid.New().String(),
+		Amount:        10.00,
+		ExecuteAt:     time.Now().Add(50 * time.Millisecond),
+	})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.PaymentStatusScheduled, due.Status)
+
+	later, err := svc.ProcessPayment(ctx, payment.PaymentParams{
+		CorrelationID: uuid.New().String(),
+		Amount:        10.00,
+		ExecuteAt:     time.Now().Add(time.Hour),
+	})
+	assert.NoError(s.T(), err)
+
+	cancelled, err := svc.CancelPayment(ctx, later.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), payment.PaymentStatusCancelled, cancelled.Status)
+
+	time.Sleep(60 * time.Millisecond)
+	n, err := svc.DispatchScheduledPayments(ctx)
+	assert.NoError(s.T(), err)
+	assert.GreaterOrEqual(s.T(), n, 1)
+
+	// Já saiu do scheduler: uma segunda rodada não enfileira de novo.
+	n, err = svc.DispatchScheduledPayments(ctx)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 0, n)
+
+	saved, err := s.r.FindPaymentByID(ctx, due.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), saved.ExecuteAt.Equal(due.ExecuteAt))
+}
+
+func (s *RepositoryTestSuite) TestPaymentAttributes() {
+	ctx := context.Background()
+	svc, _, closeFn := s.newServiceWithFakeProcessor(payment.TimestampAtReceipt)
+	defer closeFn()
+
+	merchant := "merchant-" + uuid.New().String()[:8]
+	params := payment.PaymentParams{
+		CorrelationID: uuid.New().String(),
+		Amount:        25.00,
+		MerchantID:    merchant,
+		Description:   "assinatura mensal",
+		Currency:      "BRL",
+		Metadata:      map[string]string{"plan": "pro"},
+	}
+	p, err := svc.ProcessPayment(ctx, params)
+	assert.NoError(s.T(), err)
+	assert.NoError(s.T(), svc.ProcessPaymentAsync(ctx, p))
+
+	other := payment.Payment{
+		CorrelationID: uuid.New().String(),
+		Amount:        99.00,
+		Status:        payment.PaymentStatusPending,
+		StartedAt:     p.StartedAt,
+	}
+	assert.NoError(s.T(), svc.ProcessPaymentAsync(ctx, other))
+
+	saved, err := svc.FindPayment(ctx, p.CorrelationID)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), merchant, saved.MerchantID)
+	assert.Equal(s.T(), "assinatura mensal", saved.Description)
+	assert.Equal(s.T(), "BRL", saved.Currency)
+	assert.Equal(s.T(), map[string]string{"plan": "pro"}, saved.Metadata)
+
+	summary, err := svc.GetPaymentsSummary(ctx, payment.PaymentSummaryParams{MerchantID: merchant})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, summary.Default.TotalRequests+summary.Fallback.TotalRequests)
+	assert.InDelta(s.T(), 25.0, summary.Default.TotalAmount+summary.Fallback.TotalAmount, 0.001)
+}
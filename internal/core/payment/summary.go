@@ -0,0 +1,191 @@
+package payment
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/money"
+)
+
+// statusRank ordena os estados de um pagamento. Cada SavePayment grava um novo
+// membro no sorted set, então um mesmo pagamento aparece várias vezes na
+// janela; o estado mais avançado é o que vale.
+func statusRank(s PaymentStatus) int {
+	switch s {
+	case PaymentStatusRefunded:
+		return 4
+	case PaymentStatusPartiallyRefunded:
+		return 3
+	case PaymentStatusSuccess, PaymentStatusDead, PaymentStatusCancelled:
+		return 2
+	case PaymentStatusFailed:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// summarizeDetails agrega os pagamentos de uma janela, já em ordem de score,
+// por processador e status. TotalRequests/TotalAmount contam os pagamentos
+// confirmados pelo processador, inclusive os estornados depois; NetAmount
+// desconta os estornos.
+func summarizeDetails(payments []Payment) PaymentSummaryDetails {
+	final := latestPayments(payments)
+
+	var details PaymentSummaryDetails
+	latencies := make(map[*ProcessorSummary][]int64)
+
+	for _, p := range final {
+		var ps *ProcessorSummary
+		switch p.Processor {
+		case string(externalservices.ProcessorDefault):
+			ps = &details.Default
+		case string(externalservices.ProcessorFallback):
+			ps = &details.Fallback
+		default:
+			ps = &details.Unassigned
+		}
+
+		if ps.ByStatus == nil {
+			ps.ByStatus = make(map[PaymentStatus]StatusTotals)
+		}
+		st := ps.ByStatus[p.Status]
+		st.Count++
+		st.Amount += p.Amount
+		ps.ByStatus[p.Status] = st
+
+		switch {
+		case p.settled():
+			ps.TotalRequests++
+			ps.TotalAmount += p.Amount
+			ps.RefundedAmount += p.RefundedAmount
+		case p.Status == PaymentStatusFailed:
+			ps.FailedAmount += p.Amount
+		case p.Status == PaymentStatusDead:
+			ps.DeadAmount += p.Amount
+		}
+
+		if p.LatencyMs > 0 {
+			latencies[ps] = append(latencies[ps], p.LatencyMs)
+		}
+	}
+
+	for _, ps := range []*ProcessorSummary{&details.Default, &details.Fallback, &details.Unassigned} {
+		if ps.ByStatus == nil {
+			ps.ByStatus = make(map[PaymentStatus]StatusTotals)
+		}
+		ps.TotalAmount = roundCents(ps.TotalAmount)
+		ps.FailedAmount = roundCents(ps.FailedAmount)
+		ps.DeadAmount = roundCents(ps.DeadAmount)
+		ps.RefundedAmount = roundCents(ps.RefundedAmount)
+		ps.NetAmount = roundCents(ps.TotalAmount - ps.RefundedAmount)
+		for status, st := range ps.ByStatus {
+			st.Amount = roundCents(st.Amount)
+			ps.ByStatus[status] = st
+		}
+
+		l := latencies[ps]
+		slices.Sort(l)
+		ps.Latency = LatencyPercentiles{
+			P50: percentile(l, 50),
+			P95: percentile(l, 95),
+			P99: percentile(l, 99),
+		}
+	}
+
+	return details
+}
+
+// latestPayments mantém, para cada correlationId, o membro com o estado mais
+// avançado, preservando a ordem da primeira aparição. Entre estornos parciais
+// vale o de maior refundedAmount, que só cresce.
+func latestPayments(payments []Payment) []Payment {
+	latest := make(map[string]Payment, len(payments))
+	var order []string
+	var final []Payment
+
+	for _, p := range payments {
+		// Membros antigos não têm correlationId e não podem ser deduplicados.
+		if p.CorrelationID == "" {
+			final = append(final, p)
+			continue
+		}
+		prev, ok := latest[p.CorrelationID]
+		if !ok {
+			order = append(order, p.CorrelationID)
+		}
+		rank, prevRank := statusRank(p.Status), statusRank(prev.Status)
+		if !ok || rank > prevRank || (rank == prevRank && p.RefundedAmount >= prev.RefundedAmount) {
+			latest[p.CorrelationID] = p
+		}
+	}
+
+	for _, id := range order {
+		final = append(final, latest[id])
+	}
+	return final
+}
+
+// bucketize distribui os pagamentos confirmados em buckets de params.Step
+// alinhados ao relógio. Buckets vazios também são devolvidos.
+func bucketize(payments []Payment, params TimeSeriesParams) PaymentTimeSeries {
+	start := params.From.Truncate(params.Step)
+	n := int(params.To.Sub(start)/params.Step) + 1
+
+	buckets := make([]TimeSeriesBucket, n)
+	for i := range buckets {
+		buckets[i].Start = start.Add(time.Duration(i) * params.Step).UTC()
+	}
+
+	for _, p := range latestPayments(payments) {
+		if !p.settled() {
+			continue
+		}
+		i := int(p.timestamp().Sub(start) / params.Step)
+		if i < 0 || i >= n {
+			continue
+		}
+
+		var t *totalPayments
+		switch p.Processor {
+		case string(externalservices.ProcessorDefault):
+			t = &buckets[i].Default
+		case string(externalservices.ProcessorFallback):
+			t = &buckets[i].Fallback
+		default:
+			continue
+		}
+		t.TotalRequests++
+		t.TotalAmount = roundCents(t.TotalAmount + p.Amount)
+	}
+
+	return PaymentTimeSeries{
+		Step:    stepToken(params.Step),
+		Buckets: buckets,
+	}
+}
+
+// stepToken devolve o step como o cliente pediu ("1m", e não "1m0s").
+func stepToken(step time.Duration) string {
+	for token, d := range timeSeriesSteps {
+		if d == step {
+			return token
+		}
+	}
+	return step.String()
+}
+
+// percentile usa o método nearest-rank sobre uma amostra já ordenada.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+func roundCents(amount float64) float64 {
+	return money.ToFloat(money.ToCents(amount))
+}
@@ -0,0 +1,99 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeDetails(t *testing.T) {
+	def := string(externalservices.ProcessorDefault)
+	fb := string(externalservices.ProcessorFallback)
+
+	payments := []Payment{
+		{CorrelationID: "a", Amount: 10, Status: PaymentStatusPending},
+		{CorrelationID: "a", Amount: 10, Processor: def, Status: PaymentStatusFailed, LatencyMs: 400},
+		{CorrelationID: "a", Amount: 10, Processor: def, Status: PaymentStatusSuccess, LatencyMs: 10},
+		{CorrelationID: "b", Amount: 20, Processor: def, Status: PaymentStatusSuccess, LatencyMs: 20},
+		{CorrelationID: "c", Amount: 5.5, Processor: def, Status: PaymentStatusFailed, LatencyMs: 30},
+		{CorrelationID: "d", Amount: 100, Processor: fb, Status: PaymentStatusSuccess, LatencyMs: 50},
+		{CorrelationID: "e", Amount: 7, Status: PaymentStatusPending},
+		{CorrelationID: "f", Amount: 3, Status: PaymentStatusDead},
+	}
+
+	d := summarizeDetails(payments)
+
+	assert.Equal(t, 2, d.Default.TotalRequests)
+	assert.InDelta(t, 30.0, d.Default.TotalAmount, 0.001)
+	assert.InDelta(t, 5.5, d.Default.FailedAmount, 0.001)
+	assert.Equal(t, StatusTotals{Count: 2, Amount: 30}, d.Default.ByStatus[PaymentStatusSuccess])
+	assert.Equal(t, StatusTotals{Count: 1, Amount: 5.5}, d.Default.ByStatus[PaymentStatusFailed])
+	assert.Equal(t, LatencyPercentiles{P50: 20, P95: 30, P99: 30}, d.Default.Latency)
+
+	assert.Equal(t, 1, d.Fallback.TotalRequests)
+	assert.InDelta(t, 100.0, d.Fallback.TotalAmount, 0.001)
+
+	assert.Equal(t, 0, d.Unassigned.TotalRequests)
+	assert.Equal(t, 1, d.Unassigned.ByStatus[PaymentStatusPending].Count)
+	assert.InDelta(t, 3.0, d.Unassigned.DeadAmount, 0.001)
+}
+
+func TestSummarizeDetails_NetOfRefunds(t *testing.T) {
+	def := string(externalservices.ProcessorDefault)
+
+	// Membros com o mesmo score saem em ordem lexicográfica, então o estorno
+	// maior pode aparecer antes do menor.
+	payments := []Payment{
+		{CorrelationID: "a", Amount: 50, Processor: def, Status: PaymentStatusSuccess},
+		{CorrelationID: "a", Amount: 50, Processor: def, Status: PaymentStatusPartiallyRefunded, RefundedAmount: 20},
+		{CorrelationID: "a", Amount: 50, Processor: def, Status: PaymentStatusPartiallyRefunded, RefundedAmount: 10},
+		{CorrelationID: "b", Amount: 30, Processor: def, Status: PaymentStatusSuccess},
+		{CorrelationID: "b", Amount: 30, Processor: def, Status: PaymentStatusRefunded, RefundedAmount: 30},
+		{CorrelationID: "c", Amount: 5, Status: PaymentStatusPending},
+		{CorrelationID: "c", Amount: 5, Status: PaymentStatusCancelled},
+	}
+
+	d := summarizeDetails(payments)
+
+	assert.Equal(t, 2, d.Default.TotalRequests)
+	assert.InDelta(t, 80.0, d.Default.TotalAmount, 0.001)
+	assert.InDelta(t, 50.0, d.Default.RefundedAmount, 0.001)
+	assert.InDelta(t, 30.0, d.Default.NetAmount, 0.001)
+	assert.Equal(t, 1, d.Unassigned.ByStatus[PaymentStatusCancelled].Count)
+	assert.Equal(t, 0, d.Unassigned.ByStatus[PaymentStatusPending].Count)
+}
+
+func TestPercentile(t *testing.T) {
+	sample := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Equal(t, int64(0), percentile(nil, 50))
+	assert.Equal(t, int64(5), percentile(sample, 50))
+	assert.Equal(t, int64(10), percentile(sample, 95))
+	assert.Equal(t, int64(1), percentile(sample, 0))
+}
+
+func TestBucketize(t *testing.T) {
+	def := string(externalservices.ProcessorDefault)
+	fb := string(externalservices.ProcessorFallback)
+	from := time.Date(2025, 7, 10, 12, 0, 30, 0, time.UTC)
+	to := from.Add(2 * time.Minute)
+
+	payments := []Payment{
+		{CorrelationID: "a", Amount: 10, Processor: def, Status: PaymentStatusSuccess, RequestedAt: from},
+		{CorrelationID: "b", Amount: 5, Processor: fb, Status: PaymentStatusSuccess, RequestedAt: from.Add(20 * time.Second)},
+		{CorrelationID: "c", Amount: 1, Processor: def, Status: PaymentStatusFailed, RequestedAt: from.Add(40 * time.Second)},
+		{CorrelationID: "d", Amount: 2.5, Processor: def, Status: PaymentStatusSuccess, RequestedAt: to},
+	}
+
+	series := bucketize(payments, TimeSeriesParams{From: from, To: to, Step: time.Minute})
+
+	assert.Equal(t, "1m", series.Step)
+	assert.Len(t, series.Buckets, 3)
+	assert.Equal(t, from.Truncate(time.Minute), series.Buckets[0].Start)
+	assert.Equal(t, totalPayments{TotalRequests: 1, TotalAmount: 10}, series.Buckets[0].Default)
+	assert.Equal(t, totalPayments{TotalRequests: 1, TotalAmount: 5}, series.Buckets[0].Fallback)
+	assert.Equal(t, totalPayments{}, series.Buckets[1].Default)
+	assert.Equal(t, totalPayments{TotalRequests: 1, TotalAmount: 2.5}, series.Buckets[2].Default)
+}
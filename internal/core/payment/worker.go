@@ -2,17 +2,24 @@ package payment
 
 import (
 	"context"
-	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
 	externalservices "github.com/oprimogus/rinha-backend-2025/internal/core/external_services"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
 	"golang.org/x/sync/errgroup"
 )
 
+var workerLog = logger.Component("worker")
+
 var (
-	paymentQueue    = make(chan Payment, 10000)
-	paymentErrQueue = make(chan Payment, 1000)
+	// As filas são criadas por NewPaymentWorker, com a configuração já
+	// validada. Antes disso SendToQueue recusa tudo.
+	paymentQueue    chan Payment
+	paymentErrQueue chan Payment
 	paymentPool     = sync.Pool{
 		New: func() any {
 			return &Payment{}
@@ -26,17 +33,34 @@ func SendToQueue(payment Payment) bool {
 		return true
 	default:
 		// Do back pressure
-		slog.Warn("payment queue is full, dropping message")
+		workerLog.Warn("payment queue is full, dropping message")
 		return false
 	}
 }
 
+// QueueStats é a ocupação das filas em memória desta instância.
+type QueueStats struct {
+	Size          int
+	Capacity      int
+	ErrorSize     int
+	ErrorCapacity int
+}
+
+func GetQueueStats() QueueStats {
+	return QueueStats{
+		Size:          len(paymentQueue),
+		Capacity:      cap(paymentQueue),
+		ErrorSize:     len(paymentErrQueue),
+		ErrorCapacity: cap(paymentErrQueue),
+	}
+}
+
 func ReprocessPayment(payment Payment) bool {
 	select {
 	case paymentErrQueue <- payment:
 		return true
 	default:
-		slog.Error("error queue is full, dropping failed payment")
+		workerLog.Error("error queue is full, dropping failed payment")
 		return false
 	}
 }
@@ -44,75 +68,146 @@ func ReprocessPayment(payment Payment) bool {
 type PaymentWorker struct {
 	r       Repository
 	service *Service
+	cfg     config.Payment
 
 	workerCount int
 	wg          sync.WaitGroup
 
+	// quit é cancelado por Shutdown e para tudo que Run iniciou.
+	quit context.Context
+	stop context.CancelFunc
+
 	processed  int64
 	failed     int64
 	metricsMux sync.RWMutex
 
-	rateLimiter chan struct{}
+	concurrency *concurrencyLimit
+
+	// heartbeats guarda, por worker, o último instante (UnixNano) em que ele
+	// voltou ao loop. Zero é worker que não iniciou ou já parou.
+	heartbeats []atomic.Int64
 }
 
-func NewPaymentWorker(repository Repository, workerCount int) *PaymentWorker {
+const workerHeartbeat = time.Second
+
+func NewPaymentWorker(repository Repository, cfg config.Payment) *PaymentWorker {
+	paymentQueue = make(chan Payment, cfg.QueueSize)
+	paymentErrQueue = make(chan Payment, cfg.ErrorQueueSize)
+	quit, stop := context.WithCancel(context.Background())
 	return &PaymentWorker{
 		r:           repository,
 		service:     NewService(repository),
-		workerCount: workerCount,
-		rateLimiter: make(chan struct{}, workerCount*2),
+		cfg:         cfg,
+		workerCount: cfg.Workers,
+		concurrency: newConcurrencyLimit(cfg.MaxConcurrency),
+		heartbeats:  make([]atomic.Int64, cfg.Workers),
+		quit:        quit,
+		stop:        stop,
 	}
 }
 
 func (w *PaymentWorker) Run(ctx context.Context, workers int) {
-	go w.StartHealthCheckJob(ctx, 8*time.Second)
+	ctx, cancel := context.WithCancel(ctx)
+	context.AfterFunc(w.quit, cancel)
+
+	go w.StartHealthCheckJob(ctx, w.cfg.HealthCheckInterval)
+	
+	// Scheduler e reprocessamento escrevem nas filas: entram no wg para que
+	// Shutdown espere por eles.
+	w.wg.Add(2)
+	go func() {
+		defer w.wg.Done()
+		w.StartSchedulerJob(ctx, w.cfg.SchedulerInterval)
+	}()
 	
 	w.StartProcessPaymentsWorker(ctx)
 	
-	go w.StartErrorReprocessingWorker(ctx)
+	go func() {
+		defer w.wg.Done()
+		w.StartErrorReprocessingWorker(ctx)
+	}()
 	
 	go w.StartMetricsWorker(ctx)
 }
 
 func (w *PaymentWorker) StartHealthCheckJob(ctx context.Context, interval time.Duration) {
-	slog.Info("Starting health check job...")
+	workerLog.Info("Starting health check job...")
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Info("Finalizing health check job...")
+			workerLog.Info("Finalizing health check job...")
 			return
 		case <-ticker.C:
 			go func() {
-				ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
+				ctxTimeout, cancel := context.WithTimeout(ctx, w.cfg.HealthCheckTimeout)
 				defer cancel()
 				
 				if err := w.GetHealthStatus(ctxTimeout); err != nil {
-					slog.Warn("health check failed", "error", err)
+					workerLog.Warn("health check failed", "error", err)
 				}
 			}()
 		}
 	}
 }
 
+// StartSchedulerJob move para a fila os pagamentos agendados que venceram. O
+// agendamento fica no Redis, então sobrevive a restarts e pode rodar nas duas
+// instâncias ao mesmo tempo.
+func (w *PaymentWorker) StartSchedulerJob(ctx context.Context, interval time.Duration) {
+	workerLog.Info("Starting payment scheduler job...")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			workerLog.Info("Finalizing payment scheduler job...")
+			return
+		case <-ticker.C:
+			for _, id := range w.service.Tenants() {
+				w.dispatchScheduledPayments(tenant.WithTenant(ctx, id))
+			}
+		}
+	}
+}
+
+func (w *PaymentWorker) dispatchScheduledPayments(ctx context.Context) {
+	for {
+		n, err := w.service.DispatchScheduledPayments(ctx)
+		if err != nil {
+			workerLog.Warn("fail on dispatch scheduled payments", "tenant", tenant.FromContext(ctx), "error", err)
+		}
+		if n < w.cfg.ScheduleBatch {
+			return
+		}
+	}
+}
+
 func (w *PaymentWorker) GetHealthStatus(ctx context.Context) error {
-	slog.InfoContext(ctx, "Searching health status")
+	workerLog.InfoContext(ctx, "Searching health status")
 	
-	g, ctx := errgroup.WithContext(ctx)
+	// Sem WithContext: a falha de um tenant não pode cancelar o health check
+	// dos outros.
+	var g errgroup.Group
 	
-	// Processa health checks em paralelo
-	g.Go(func() error {
-		return w.checkProcessorHealth(ctx, externalservices.ProcessorDefault)
-	})
-	
-	g.Go(func() error {
-		return w.checkProcessorHealth(ctx, externalservices.ProcessorFallback)
-	})
+	// Processa health checks em paralelo, para os processadores de cada tenant
+	for _, id := range w.service.Tenants() {
+		tenantCtx := tenant.WithTenant(ctx, id)
+
+		g.Go(func() error {
+			return w.checkProcessorHealth(tenantCtx, externalservices.ProcessorDefault)
+		})
+
+		g.Go(func() error {
+			return w.checkProcessorHealth(tenantCtx, externalservices.ProcessorFallback)
+		})
+	}
 	
 	if err := g.Wait(); err != nil {
-		slog.Error("fail on get health check", "error", err)
+		workerLog.Error("fail on get health check", "error", err)
 		return err
 	}
 	
@@ -122,13 +217,13 @@ func (w *PaymentWorker) GetHealthStatus(ctx context.Context) error {
 func (w *PaymentWorker) checkProcessorHealth(ctx context.Context, processor externalservices.ProcessorName) error {
 	h, err := w.service.GetHealthStatus(ctx, processor)
 	if err != nil {
-		slog.Info("fail on get health check status", "processor", processor, "error", err)
+		workerLog.Info("fail on get health check status", "processor", processor, "error", err)
 		return err
 	}
 	
 	err = w.r.SaveProcessorHealthStatus(ctx, processor, h)
 	if err != nil {
-		slog.Info("fail on save health check status", "processor", processor, "error", err)
+		workerLog.Info("fail on save health check status", "processor", processor, "error", err)
 		return err
 	}
 	
@@ -136,7 +231,7 @@ func (w *PaymentWorker) checkProcessorHealth(ctx context.Context, processor exte
 }
 
 func (w *PaymentWorker) StartProcessPaymentsWorker(ctx context.Context) {
-	slog.Info("Starting process payment workers", "count", w.workerCount)
+	workerLog.Info("Starting process payment workers", "count", w.workerCount)
 	
 	for i := range w.workerCount {
 		w.wg.Add(1)
@@ -147,90 +242,125 @@ func (w *PaymentWorker) StartProcessPaymentsWorker(ctx context.Context) {
 func (w *PaymentWorker) paymentWorker(ctx context.Context, workerID int) {
 	defer w.wg.Done()
 	
-	slog.Info("Payment worker started", "worker", workerID)
+	workerLog.Info("Payment worker started", "worker", workerID)
+	
+	beat := time.NewTicker(workerHeartbeat)
+	defer beat.Stop()
+	w.heartbeats[workerID].Store(time.Now().UnixNano())
+	defer w.heartbeats[workerID].Store(0)
 	
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Info("Payment worker stopped", "worker", workerID)
+			workerLog.Info("Payment worker stopped", "worker", workerID)
 			return
 			
+		case <-beat.C:
+			w.heartbeats[workerID].Store(time.Now().UnixNano())
+			
 		case payment := <-paymentQueue:
-			// Rate limiting
-			w.rateLimiter <- struct{}{}
+			// acquire só falha no shutdown: o pagamento não foi descartado, então
+			// continua pending no Redis em vez de ir para dead.
+			if !w.concurrency.acquire(ctx) {
+				workerLog.Warn("Payment left pending on shutdown", "worker", workerID, "correlation_id", payment.CorrelationID)
+				return
+			}
 			
 			// Processa pagamento
 			if err := w.processPaymentWithRetry(ctx, payment, workerID); err != nil {
 				w.incrementFailed()
 				if !ReprocessPayment(payment) {
-					slog.Error("Failed to requeue payment", "worker", workerID, "payment", payment)
+					workerLog.Error("Failed to requeue payment", "worker", workerID, "payment", payment)
+					w.markDead(ctx, payment)
 				}
 			} else {
 				w.incrementProcessed()
 			}
-			<-w.rateLimiter
+			w.concurrency.release()
+			w.heartbeats[workerID].Store(time.Now().UnixNano())
 		}
 	}
 }
 
 func (w *PaymentWorker) processPaymentWithRetry(ctx context.Context, payment Payment, workerID int) error {
 	// Timeout específico para cada processamento
-	ctxTimeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctxTimeout, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
 	defer cancel()
 	
-	slog.Info("Processing payment", "worker", workerID, "payment", payment)
+	workerLog.Info("Processing payment", "worker", workerID, "payment", payment)
 	
 	err := w.service.ProcessPaymentAsync(ctxTimeout, payment)
 	if err != nil {
-		slog.Error("Failed to process payment", "error", err, "worker", workerID)
+		workerLog.Error("Failed to process payment", "error", err, "worker", workerID)
 		return err
 	}
 	
-	slog.Info("Payment processed successfully", "worker", workerID, "payment", payment)
+	workerLog.Info("Payment processed successfully", "worker", workerID, "payment", payment)
 	return nil
 }
 
+// SetMaxConcurrency muda quantos workers processam ao mesmo tempo, sem
+// reiniciar os workers.
+func (w *PaymentWorker) SetMaxConcurrency(n int) {
+	w.concurrency.setLimit(n)
+}
+
+// AliveWorkers conta os workers que passaram pelo loop nos últimos staleAfter.
+// Um worker preso num pagamento fica sem heartbeat até o timeout do
+// processamento, então staleAfter deve ser maior que ele.
+func (w *PaymentWorker) AliveWorkers(staleAfter time.Duration) (alive, total int) {
+	since := time.Now().Add(-staleAfter).UnixNano()
+	for i := range w.heartbeats {
+		if w.heartbeats[i].Load() >= since {
+			alive++
+		}
+	}
+	return alive, len(w.heartbeats)
+}
+
 func (w *PaymentWorker) StartErrorReprocessingWorker(ctx context.Context) {
-	slog.Info("Starting error reprocessing worker...")
+	workerLog.Info("Starting error reprocessing worker...")
 
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-		
-		for {
-			select {
-			case <-ctx.Done():
-				slog.Info("Error reprocessing worker stopped")
-				return
-				
-			case <-ticker.C:
-				w.processBatchErrors(ctx)
-				
-			case payment := <-paymentErrQueue:
-				time.Sleep(5 * time.Second)
-				
-				if !SendToQueue(payment) {
-					slog.Error("Failed to requeue payment from error queue")
+	ticker := time.NewTicker(w.cfg.RetryInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			workerLog.Info("Error reprocessing worker stopped")
+			return
+			
+		case <-ticker.C:
+			w.processBatchErrors(ctx)
+			
+		case payment := <-paymentErrQueue:
+			time.Sleep(w.cfg.RetryDelay)
+			
+			if !SendToQueue(payment) {
+				select {
+				case paymentErrQueue <- payment:
+				default:
+					workerLog.Error("Failed to requeue payment from error queue", "correlation_id", payment.CorrelationID)
+					w.markDead(ctx, payment)
 				}
 			}
 		}
-	}()
+	}
 }
 
 func (w *PaymentWorker) processBatchErrors(ctx context.Context) {
 	processed := 0
-	maxBatch := 100
-	
-	for processed < maxBatch {
+	for processed < w.cfg.RetryBatch {
 		select {
 		case payment := <-paymentErrQueue:
-			time.Sleep(1 * time.Second)
+			time.Sleep(w.cfg.RetryBatchDelay)
 			
 			if !SendToQueue(payment) {
 				select {
 				case paymentErrQueue <- payment:
 				default:
-					slog.Error("Both queues are full, dropping payment")
+					workerLog.Error("Both queues are full, dropping payment")
+					w.markDead(ctx, payment)
 				}
 			}
 			processed++
@@ -241,8 +371,18 @@ func (w *PaymentWorker) processBatchErrors(ctx context.Context) {
 	}
 }
 
+// markDead registra que o pagamento foi descartado, para que ele apareça no
+// summary detalhado e deixe de contar como pendente.
+func (w *PaymentWorker) markDead(ctx context.Context, payment Payment) {
+	payment.Status = PaymentStatusDead
+	ctx = tenant.WithTenant(context.WithoutCancel(ctx), payment.TenantID)
+	if err := w.r.SavePayment(ctx, payment); err != nil {
+		workerLog.Error("Failed to mark payment as dead", "error", err, "correlation_id", payment.CorrelationID)
+	}
+}
+
 func (w *PaymentWorker) StartMetricsWorker(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := time.NewTicker(w.cfg.MetricsInterval)
 	defer ticker.Stop()
 	
 	for {
@@ -261,7 +401,7 @@ func (w *PaymentWorker) logMetrics() {
 	failed := w.failed
 	w.metricsMux.RUnlock()
 	
-	slog.Info("Worker metrics",
+	workerLog.Info("Worker metrics",
 		"processed", processed,
 		"failed", failed,
 		"queue_size", len(paymentQueue),
@@ -282,11 +422,12 @@ func (w *PaymentWorker) incrementFailed() {
 }
 
 func (w *PaymentWorker) Shutdown(ctx context.Context) error {
-	slog.Info("Shutting down payment worker...")
+	workerLog.Info("Shutting down payment worker...")
 	
-	// Fecha os canais para sinalizar parada
-	close(paymentQueue)
-	close(paymentErrQueue)
+	// Para os workers e os jobs pelo contexto. As filas não são fechadas:
+	// quem ainda chamar SendToQueue não entra em pânico, e o que ficou nelas
+	// continua pending no Redis.
+	w.stop()
 	
 	// Aguarda todos os workers terminarem
 	done := make(chan struct{})
@@ -298,10 +439,59 @@ func (w *PaymentWorker) Shutdown(ctx context.Context) error {
 	// Aguarda com timeout
 	select {
 	case <-done:
-		slog.Info("Payment worker shutdown completed")
+		workerLog.Info("Payment worker shutdown completed")
 		return nil
 	case <-ctx.Done():
-		slog.Warn("Payment worker shutdown timeout")
+		workerLog.Warn("Payment worker shutdown timeout")
 		return ctx.Err()
 	}
 }
+
+// concurrencyLimit é um semáforo cujo limite pode mudar em runtime.
+type concurrencyLimit struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func newConcurrencyLimit(limit int) *concurrencyLimit {
+	c := &concurrencyLimit{limit: limit}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// acquire espera uma vaga e devolve false se o ctx acabar antes.
+func (c *concurrencyLimit) acquire(ctx context.Context) bool {
+	stop := context.AfterFunc(ctx, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer stop()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.active >= c.limit {
+		if ctx.Err() != nil {
+			return false
+		}
+		c.cond.Wait()
+	}
+	c.active++
+	return true
+}
+
+func (c *concurrencyLimit) release() {
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+	c.cond.Signal()
+}
+
+func (c *concurrencyLimit) setLimit(limit int) {
+	c.mu.Lock()
+	c.limit = limit
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
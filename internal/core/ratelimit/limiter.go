@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+func init() {
+	xerror.Register(ErrRateLimited, xerror.Mapping{Status: http.StatusTooManyRequests, Code: "rate_limited"})
+}
+
+// Result é a decisão para uma requisição e o estado do bucket depois dela.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter é quanto esperar até a próxima requisição ser aceita.
+	RetryAfter time.Duration
+	// Reset é quanto falta para o bucket voltar a ficar cheio.
+	Reset time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, l config.Limit) (Result, error)
+}
+
+// gcra guarda em KEYS[1] o "theoretical arrival time" (TAT) em microssegundos.
+// Cada requisição empurra o TAT em um intervalo (período/rate); ela é aceita
+// se o novo TAT não passar de agora + burst intervalos. O relógio é o do
+// Redis, então as duas instâncias da API compartilham o mesmo limite.
+// ARGV: intervalo em µs, burst.
+// Retorna {aceita, restantes, retryAfter µs, reset µs}.
+var gcra = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local newTat = tat + interval
+local allowAt = newTat - burst * interval
+if now < allowAt then
+	return {0, 0, allowAt - now, tat - now}
+end
+
+redis.call('SET', KEYS[1], string.format('%d', newTat), 'PX', math.ceil((newTat - now) / 1000))
+return {1, math.floor((now - allowAt) / interval), 0, newTat - now}
+`)
+
+type redisLimiter struct {
+	rdb *database.Redis
+}
+
+func NewLimiter(redis *database.Redis) Limiter {
+	return &redisLimiter{
+		rdb: redis,
+	}
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, limit config.Limit) (Result, error) {
+	interval := max(limit.Period.Microseconds()/int64(limit.Rate), 1)
+	v, err := gcra.Run(ctx, l.rdb, []string{key}, interval, limit.Burst).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    v[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(v[1]),
+		RetryAfter: time.Duration(v[2]) * time.Microsecond,
+		Reset:      time.Duration(v[3]) * time.Microsecond,
+	}, nil
+}
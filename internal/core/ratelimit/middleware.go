@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/apikey"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+const keyPrefix = "ratelimit:"
+
+// Middleware aplica o limite da rota (padrão de rota do chi, ex.: "GET
+// /payments/{id}") por API key ou, sem chave, por IP. Precisa rodar depois do
+// apikey.Middleware. Se o Redis falhar a requisição passa: o limite protege a
+// fila, não deve derrubar a API. current é lido a cada requisição, para que
+// os limites mudem com o hot reload da configuração.
+func Middleware(l Limiter, current func() config.RateLimit, routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := current()
+			if !cfg.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			pattern := routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+			if pattern == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			route := r.Method + " " + pattern
+			limit, ok := cfg.Routes[route]
+			if !ok {
+				limit = cfg.Default
+			}
+			if limit.Rate == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := l.Allow(r.Context(), keyPrefix+route+":"+subject(r, cfg.TrustProxy), limit)
+			if err != nil {
+				slog.WarnContext(r.Context(), "Rate limiter unavailable, allowing request", "route", route, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			window := time.Duration(limit.Burst) * limit.Period / time.Duration(limit.Rate)
+			w.Header().Set("RateLimit-Policy", strconv.Itoa(limit.Burst)+";w="+seconds(window))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(res.Reset))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", seconds(res.RetryAfter))
+				xerror.Write(w, r, ErrRateLimited)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func subject(r *http.Request, trustProxy bool) string {
+	if k, ok := apikey.FromContext(r.Context()); ok {
+		return "key:" + k.ID
+	}
+	if ip := r.Header.Get("X-Real-IP"); trustProxy && ip != "" {
+		return "ip:" + ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds arredonda para cima: "0" faria o cliente tentar de novo na hora.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
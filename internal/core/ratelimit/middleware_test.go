@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/apikey"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLimiter struct {
+	keys   []string
+	result Result
+}
+
+func (f *fakeLimiter) Allow(_ context.Context, key string, l config.Limit) (Result, error) {
+	f.keys = append(f.keys, key)
+	f.result.Limit = l.Burst
+	return f.result, nil
+}
+
+func newRouter(l Limiter, cfg config.RateLimit, key *apikey.Key) *chi.Mux {
+	r := chi.NewRouter()
+	if key != nil {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				next.ServeHTTP(w, req.WithContext(apikey.WithKey(req.Context(), *key)))
+			})
+		})
+	}
+	r.Use(Middleware(l, func() config.RateLimit { return cfg }, r))
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	r.Get("/payments/{id}", ok)
+	r.Post("/payments", ok)
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	cfg := config.RateLimit{
+		Enabled: true,
+		Default: config.Limit{Rate: 10, Period: time.Second, Burst: 20},
+		Routes: map[string]config.Limit{
+			"POST /payments": {},
+		},
+	}
+
+	l := &fakeLimiter{result: Result{Allowed: true, Remaining: 19, Reset: 100 * time.Millisecond}}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/payments/abc", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	newRouter(l, cfg, nil).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, []string{"ratelimit:GET /payments/{id}:ip:10.0.0.1"}, l.keys)
+	assert.Equal(t, "20", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "19", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "20;w=2", rec.Header().Get("RateLimit-Policy"))
+
+	// Rota com limite "off" não consulta o Redis
+	rec = httptest.NewRecorder()
+	newRouter(l, cfg, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/payments", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Len(t, l.keys, 1)
+
+	l = &fakeLimiter{result: Result{RetryAfter: 1500 * time.Millisecond, Reset: 2 * time.Second}}
+	rec = httptest.NewRecorder()
+	newRouter(l, cfg, &apikey.Key{ID: "k1"}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/payments/abc", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, []string{"ratelimit:GET /payments/{id}:key:k1"}, l.keys)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+}
+
+func TestMiddleware_Disabled(t *testing.T) {
+	l := &fakeLimiter{}
+	rec := httptest.NewRecorder()
+	newRouter(l, config.RateLimit{}, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/payments/abc", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, l.keys)
+	assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+}
@@ -0,0 +1,68 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+// Header identifica o tenant da requisição. Sem header a requisição é do
+// tenant padrão, que usa as chaves do Redis sem prefixo.
+const Header = "X-Tenant-Id"
+
+const (
+	Default = ""
+
+	maxIDLength = 32
+)
+
+var (
+	ErrInvalidTenant = errors.New("tenant id must have up to 32 lowercase letters, digits, '-' or '_'")
+	ErrUnknownTenant = errors.New("unknown tenant")
+)
+
+func init() {
+	xerror.Register(ErrInvalidTenant, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_tenant", Field: "tenantId"})
+	xerror.Register(ErrUnknownTenant, xerror.Mapping{Status: http.StatusNotFound, Code: "unknown_tenant"})
+}
+
+type contextKey struct{}
+
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext devolve o tenant do contexto, ou Default se não houver.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Key prefixa a chave do Redis com o tenant do contexto. O tenant padrão
+// mantém as chaves originais para não quebrar os dados já gravados.
+func Key(ctx context.Context, key string) string {
+	return KeyFor(FromContext(ctx), key)
+}
+
+func KeyFor(id, key string) string {
+	if id == Default {
+		return key
+	}
+	return "tenant:" + id + ":" + key
+}
+
+func Validate(id string) error {
+	if id == "" || len(id) > maxIDLength {
+		return ErrInvalidTenant
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return ErrInvalidTenant
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "payments", Key(ctx, "payments"))
+	assert.Equal(t, "tenant:acme:payments", Key(WithTenant(ctx, "acme"), "payments"))
+	assert.Equal(t, "payments", KeyFor(Default, "payments"))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("acme-br_01"))
+	assert.ErrorIs(t, Validate(""), ErrInvalidTenant)
+	assert.ErrorIs(t, Validate("Acme"), ErrInvalidTenant)
+	assert.ErrorIs(t, Validate("acme:payments"), ErrInvalidTenant)
+	assert.ErrorIs(t, Validate(strings.Repeat("a", 33)), ErrInvalidTenant)
+}
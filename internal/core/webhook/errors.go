@@ -0,0 +1,22 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+var (
+	ErrDeliveryNotFound      = errors.New("webhook delivery not found")
+	ErrClientWebhookNotFound = errors.New("client webhook not found")
+	ErrInvalidURL            = errors.New("url must be an absolute http(s) URL to a public host")
+	ErrInvalidSignature      = errors.New("invalid webhook signature")
+	ErrSignatureExpired      = errors.New("webhook signature timestamp out of tolerance")
+)
+
+func init() {
+	xerror.Register(ErrDeliveryNotFound, xerror.Mapping{Status: http.StatusNotFound, Code: "webhook_delivery_not_found"})
+	xerror.Register(ErrClientWebhookNotFound, xerror.Mapping{Status: http.StatusNotFound, Code: "client_webhook_not_found"})
+	xerror.Register(ErrInvalidURL, xerror.Mapping{Status: http.StatusBadRequest, Code: "invalid_url", Field: "url"})
+}
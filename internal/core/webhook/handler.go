@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/apikey"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/xerror"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+func (h *Handler) putClientWebhook(w http.ResponseWriter, r *http.Request) {
+	var params ClientWebhookParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		xerror.Write(w, r, xerror.NewCustomError(http.StatusBadRequest, "invalid webhook data", err))
+		return
+	}
+
+	cw, err := h.service.RegisterClientWebhook(r.Context(), chi.URLParam(r, "clientId"), params)
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cw)
+}
+
+func (h *Handler) getClientWebhook(w http.ResponseWriter, r *http.Request) {
+	cw, err := h.service.FindClientWebhook(r.Context(), chi.URLParam(r, "clientId"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cw)
+}
+
+func (h *Handler) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	correlationID := r.URL.Query().Get("correlationId")
+	if correlationID == "" {
+		xerror.Write(w, r, xerror.NewValidationError("correlationId", "missing 'correlationId'", nil))
+		return
+	}
+
+	deliveries, err := h.service.ListDeliveries(r.Context(), correlationID)
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (h *Handler) getDelivery(w http.ResponseWriter, r *http.Request) {
+	d, err := h.service.FindDelivery(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(d)
+}
+
+func (h *Handler) redeliver(w http.ResponseWriter, r *http.Request) {
+	d, err := h.service.Redeliver(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		xerror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(d)
+}
+
+func SetupRoutes(r *chi.Mux, db *database.Redis) {
+	handler := NewHandler(NewService(NewRepository(db)))
+	read := r.With(apikey.Require(apikey.ScopeRead))
+	submit := r.With(apikey.Require(apikey.ScopeSubmit))
+	// Trocar a URL e o segredo de um cliente desvia os webhooks dele: só admin.
+	r.With(apikey.Require(apikey.ScopeAdmin)).Put("/webhooks/clients/{clientId}", handler.putClientWebhook)
+	read.Get("/webhooks/clients/{clientId}", handler.getClientWebhook)
+	read.Get("/webhooks/deliveries", handler.listDeliveries)
+	read.Get("/webhooks/deliveries/{id}", handler.getDelivery)
+	submit.Post("/webhooks/deliveries/{id}/redeliver", handler.redeliver)
+}
@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// As entregas e a fila de retentativas são globais, porque o id da entrega
+// deriva do id do evento; cadastros de clientes e listas por pagamento ficam
+// no namespace do tenant.
+const (
+	clientKeyPrefix     = "webhooks:client:"
+	deliveryKeyPrefix   = "webhooks:delivery:"
+	paymentDeliveriesKP = "webhooks:deliveries:"
+	retryKey            = "webhooks:retry"
+)
+
+type Repository interface {
+	SaveClientWebhook(ctx context.Context, w ClientWebhook) error
+	FindClientWebhook(ctx context.Context, clientID string) (ClientWebhook, error)
+	CreateDelivery(ctx context.Context, d Delivery) error
+	SaveDelivery(ctx context.Context, d Delivery) error
+	FindDelivery(ctx context.Context, id string) (Delivery, error)
+	ListDeliveries(ctx context.Context, correlationID string) ([]Delivery, error)
+	ScheduleDelivery(ctx context.Context, id string, at time.Time) error
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]string, error)
+	CompleteDelivery(ctx context.Context, id string) error
+}
+
+type repository struct {
+	rdb *database.Redis
+}
+
+func NewRepository(redis *database.Redis) Repository {
+	return &repository{
+		rdb: redis,
+	}
+}
+
+func (r *repository) SaveClientWebhook(ctx context.Context, w ClientWebhook) error {
+	return r.rdb.HSet(ctx, tenant.Key(ctx, clientKeyPrefix+w.ClientID), map[string]any{
+		"url":    w.URL,
+		"secret": w.Secret,
+	}).Err()
+}
+
+func (r *repository) FindClientWebhook(ctx context.Context, clientID string) (ClientWebhook, error) {
+	v, err := r.rdb.HGetAll(ctx, tenant.Key(ctx, clientKeyPrefix+clientID)).Result()
+	if err != nil {
+		return ClientWebhook{}, err
+	}
+	if len(v) == 0 {
+		return ClientWebhook{}, ErrClientWebhookNotFound
+	}
+	return ClientWebhook{
+		ClientID: clientID,
+		URL:      v["url"],
+		Secret:   v["secret"],
+	}, nil
+}
+
+// createDelivery grava o hash da entrega, a lista do pagamento e o
+// agendamento numa operação só, se a entrega ainda não existir: uma queda no
+// meio não deixa entrega gravada e nunca agendada.
+// KEYS: hash, lista do pagamento, fila de retentativas. ARGV: id, score em
+// ms e os pares campo/valor do hash.
+var createDelivery = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then return 0 end
+redis.call('HSET', KEYS[1], unpack(ARGV, 3))
+redis.call('RPUSH', KEYS[2], ARGV[1])
+redis.call('ZADD', KEYS[3], ARGV[2], ARGV[1])
+return 1
+`)
+
+// CreateDelivery grava e agenda a entrega para d.NextAttemptAt apenas se o id
+// ainda não existir. Como o id deriva do id do evento, reler um evento do
+// stream não duplica entregas.
+func (r *repository) CreateDelivery(ctx context.Context, d Delivery) error {
+	args := []any{d.ID, d.NextAttemptAt.UnixMilli()}
+	for field, value := range deliveryHash(d) {
+		args = append(args, field, value)
+	}
+	keys := []string{
+		deliveryKeyPrefix + d.ID,
+		tenant.KeyFor(d.TenantID, paymentDeliveriesKP+d.CorrelationID),
+		retryKey,
+	}
+	return createDelivery.Run(ctx, r.rdb, keys, args...).Err()
+}
+
+func (r *repository) SaveDelivery(ctx context.Context, d Delivery) error {
+	return r.rdb.HSet(ctx, deliveryKeyPrefix+d.ID, deliveryHash(d)).Err()
+}
+
+func (r *repository) FindDelivery(ctx context.Context, id string) (Delivery, error) {
+	v, err := r.rdb.HGetAll(ctx, deliveryKeyPrefix+id).Result()
+	if err != nil {
+		return Delivery{}, err
+	}
+	if len(v) == 0 {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return deliveryFromHash(v)
+}
+
+func (r *repository) ListDeliveries(ctx context.Context, correlationID string) ([]Delivery, error) {
+	ids, err := r.rdb.LRange(ctx, tenant.Key(ctx, paymentDeliveriesKP+correlationID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, deliveryKeyPrefix+id)
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	deliveries := make([]Delivery, 0, len(ids))
+	for _, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		d, err := deliveryFromHash(cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func (r *repository) ScheduleDelivery(ctx context.Context, id string, at time.Time) error {
+	return r.rdb.ZAdd(ctx, retryKey, redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: id,
+	}).Err()
+}
+
+// claimDue empurra o score das entregas vencidas para o fim do lease, na
+// mesma operação em que as lê: duas instâncias nunca pegam a mesma tentativa.
+// ARGV: agora em ms, fim do lease em ms, limite.
+var claimDue = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+for _, id in ipairs(ids) do
+	redis.call('ZADD', KEYS[1], 'XX', ARGV[2], id)
+end
+return ids
+`)
+
+// ClaimDueDeliveries devolve as entregas vencidas sem tirá-las do sorted set:
+// se a tentativa não terminar (erro ou queda da instância), a entrega volta
+// quando o lease vencer. Quem termina a tentativa chama ScheduleDelivery ou
+// CompleteDelivery.
+func (r *repository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]string, error) {
+	return claimDue.Run(ctx, r.rdb, []string{retryKey}, now.UnixMilli(), now.Add(lease).UnixMilli(), limit).StringSlice()
+}
+
+// CompleteDelivery tira a entrega da fila de retentativas.
+func (r *repository) CompleteDelivery(ctx context.Context, id string) error {
+	return r.rdb.ZRem(ctx, retryKey, id).Err()
+}
+
+func deliveryHash(d Delivery) map[string]any {
+	body := map[string]any{
+		"id":             d.ID,
+		"correlationId":  d.CorrelationID,
+		"url":            d.URL,
+		"clientId":       d.ClientID,
+		"tenantId":       d.TenantID,
+		"status":         string(d.Status),
+		"attempts":       d.Attempts,
+		"lastStatusCode": d.LastStatusCode,
+		"lastError":      d.LastError,
+		"createdAt":      d.CreatedAt,
+		"updatedAt":      d.UpdatedAt,
+		"payload":        d.Payload,
+		"nextAttemptAt":  "",
+	}
+	if !d.NextAttemptAt.IsZero() {
+		body["nextAttemptAt"] = d.NextAttemptAt
+	}
+	return body
+}
+
+func deliveryFromHash(v map[string]string) (Delivery, error) {
+	d := Delivery{
+		ID:            v["id"],
+		CorrelationID: v["correlationId"],
+		URL:           v["url"],
+		ClientID:      v["clientId"],
+		TenantID:      v["tenantId"],
+		Status:        DeliveryStatus(v["status"]),
+		LastError:     v["lastError"],
+		Payload:       v["payload"],
+	}
+
+	var err error
+	if d.Attempts, err = strconv.Atoi(v["attempts"]); err != nil {
+		return Delivery{}, err
+	}
+	if d.LastStatusCode, err = strconv.Atoi(v["lastStatusCode"]); err != nil {
+		return Delivery{}, err
+	}
+	if d.CreatedAt, err = time.Parse(time.RFC3339Nano, v["createdAt"]); err != nil {
+		return Delivery{}, err
+	}
+	if d.UpdatedAt, err = time.Parse(time.RFC3339Nano, v["updatedAt"]); err != nil {
+		return Delivery{}, err
+	}
+	if next := v["nextAttemptAt"]; next != "" {
+		if d.NextAttemptAt, err = time.Parse(time.RFC3339Nano, next); err != nil {
+			return Delivery{}, err
+		}
+	}
+	return d, nil
+}
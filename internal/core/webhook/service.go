@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/core/tenant"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/egress"
+)
+
+const (
+	maxAttempts = 8
+	baseBackoff = time.Second
+	maxBackoff  = 5 * time.Minute
+	sendTimeout = 5 * time.Second
+	// deliveryLease é quanto uma tentativa reivindicada fica reservada; passa
+	// bem do sendTimeout, porque o lote reivindicado é entregue em paralelo.
+	deliveryLease = 30 * time.Second
+	maxErrorBody  = 512
+)
+
+type Service struct {
+	r      Repository
+	secret string
+	client *http.Client
+}
+
+func NewService(r Repository) *Service {
+	cfg := config.GetInstance()
+	return &Service{
+		r:      r,
+		secret: cfg.Webhook.Secret,
+		// callbackUrl e URLs de clientes vêm de fora: nada de endereços internos.
+		client: egress.Client(sendTimeout),
+	}
+}
+
+// RegisterClientWebhook cadastra (ou substitui) o endpoint do cliente e gera
+// um novo segredo, devolvido apenas nesta resposta.
+func (s *Service) RegisterClientWebhook(ctx context.Context, clientID string, params ClientWebhookParams) (ClientWebhook, error) {
+	if err := validateURL(ctx, params.URL); err != nil {
+		return ClientWebhook{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return ClientWebhook{}, err
+	}
+
+	w := ClientWebhook{
+		ClientID: clientID,
+		URL:      params.URL,
+		Secret:   hex.EncodeToString(secret),
+	}
+	if err := s.r.SaveClientWebhook(ctx, w); err != nil {
+		return ClientWebhook{}, err
+	}
+	return w, nil
+}
+
+func (s *Service) FindClientWebhook(ctx context.Context, clientID string) (ClientWebhook, error) {
+	w, err := s.r.FindClientWebhook(ctx, clientID)
+	if err != nil {
+		return ClientWebhook{}, err
+	}
+	w.Secret = ""
+	return w, nil
+}
+
+// FindDelivery só devolve entregas do tenant da requisição.
+func (s *Service) FindDelivery(ctx context.Context, id string) (Delivery, error) {
+	d, err := s.r.FindDelivery(ctx, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if d.TenantID != tenant.FromContext(ctx) {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+func (s *Service) ListDeliveries(ctx context.Context, correlationID string) ([]Delivery, error) {
+	return s.r.ListDeliveries(ctx, correlationID)
+}
+
+// Redeliver recoloca a entrega na fila com o orçamento de tentativas zerado.
+func (s *Service) Redeliver(ctx context.Context, id string) (Delivery, error) {
+	d, err := s.FindDelivery(ctx, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	now := time.Now().UTC()
+	d.Status = DeliveryStatusPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.UpdatedAt = now
+	if err := s.r.SaveDelivery(ctx, d); err != nil {
+		return Delivery{}, err
+	}
+	return d, s.r.ScheduleDelivery(ctx, d.ID, now)
+}
+
+// HandleEvent cria as entregas de um pagamento finalizado: uma para o
+// callbackUrl do pagamento e outra para o webhook do cliente, se existirem.
+func (s *Service) HandleEvent(ctx context.Context, event payment.StatusEvent) error {
+	p := event.Payment
+	if !p.Finished() {
+		return nil
+	}
+	ctx = tenant.WithTenant(ctx, p.TenantID)
+
+	var targets []Delivery
+	if p.CallbackURL != "" {
+		targets = append(targets, Delivery{ID: event.ID + "-callback", URL: p.CallbackURL})
+	}
+	if p.ClientID != "" {
+		cw, err := s.r.FindClientWebhook(ctx, p.ClientID)
+		switch {
+		case err == nil:
+			targets = append(targets, Delivery{ID: event.ID + "-client", URL: cw.URL, ClientID: p.ClientID})
+		case !errors.Is(err, ErrClientWebhookNotFound):
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, d := range targets {
+		payload, err := json.Marshal(Event{
+			ID:        d.ID,
+			Type:      EventPaymentFinished,
+			CreatedAt: now,
+			Payment:   p,
+		})
+		if err != nil {
+			return err
+		}
+
+		d.CorrelationID = p.CorrelationID
+		d.TenantID = p.TenantID
+		d.Status = DeliveryStatusPending
+		d.NextAttemptAt = now
+		d.CreatedAt = now
+		d.UpdatedAt = now
+		d.Payload = string(payload)
+
+		if err := s.r.CreateDelivery(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver faz uma tentativa de entrega e agenda a próxima em caso de falha.
+// Num erro de leitura a entrega continua na fila e volta quando o lease do
+// ClaimDueDeliveries vencer.
+func (s *Service) Deliver(ctx context.Context, id string) error {
+	d, err := s.r.FindDelivery(ctx, id)
+	if errors.Is(err, ErrDeliveryNotFound) {
+		return s.r.CompleteDelivery(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	if d.Status != DeliveryStatusPending {
+		return s.r.CompleteDelivery(ctx, id)
+	}
+
+	secret := s.secret
+	if d.ClientID != "" {
+		cw, err := s.r.FindClientWebhook(tenant.WithTenant(ctx, d.TenantID), d.ClientID)
+		if errors.Is(err, ErrClientWebhookNotFound) {
+			// O cliente removeu o webhook: não há para onde nem com que
+			// segredo entregar.
+			d.Status = DeliveryStatusFailed
+			d.LastError = err.Error()
+			d.NextAttemptAt = time.Time{}
+			d.UpdatedAt = time.Now().UTC()
+			if err := s.r.SaveDelivery(ctx, d); err != nil {
+				return err
+			}
+			return s.r.CompleteDelivery(ctx, d.ID)
+		}
+		if err != nil {
+			return err
+		}
+		secret = cw.Secret
+	}
+
+	code, sendErr := s.send(ctx, d, secret)
+
+	now := time.Now().UTC()
+	d.Attempts++
+	d.LastStatusCode = code
+	d.UpdatedAt = now
+	d.LastError = ""
+
+	switch {
+	case sendErr == nil:
+		d.Status = DeliveryStatusDelivered
+		d.NextAttemptAt = time.Time{}
+	case d.Attempts >= maxAttempts:
+		d.Status = DeliveryStatusFailed
+		d.LastError = sendErr.Error()
+		d.NextAttemptAt = time.Time{}
+		webhookLog.Warn("webhook delivery failed permanently", "delivery", d.ID, "url", d.URL, "error", sendErr)
+	default:
+		d.LastError = sendErr.Error()
+		d.NextAttemptAt = now.Add(backoff(d.Attempts))
+	}
+
+	if err := s.r.SaveDelivery(ctx, d); err != nil {
+		return err
+	}
+	if d.Status == DeliveryStatusPending {
+		return s.r.ScheduleDelivery(ctx, d.ID, d.NextAttemptAt)
+	}
+	return s.r.CompleteDelivery(ctx, d.ID)
+}
+
+func (s *Service) send(ctx context.Context, d Delivery, secret string) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, msg)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff dobra a espera a cada tentativa, até maxBackoff.
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << (attempt - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func validateURL(ctx context.Context, v string) error {
+	if err := egress.CheckURL(ctx, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	return nil
+}
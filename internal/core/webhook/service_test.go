@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 64*time.Second, backoff(7))
+	assert.Equal(t, maxBackoff, backoff(10))
+	assert.Equal(t, maxBackoff, backoff(80))
+}
+
+type memoryRepository struct {
+	clients    map[string]ClientWebhook
+	deliveries map[string]Delivery
+	retry      map[string]time.Time
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{clients: map[string]ClientWebhook{}, deliveries: map[string]Delivery{}, retry: map[string]time.Time{}}
+}
+
+func (m *memoryRepository) SaveClientWebhook(_ context.Context, w ClientWebhook) error {
+	m.clients[w.ClientID] = w
+	return nil
+}
+
+func (m *memoryRepository) FindClientWebhook(_ context.Context, clientID string) (ClientWebhook, error) {
+	w, ok := m.clients[clientID]
+	if !ok {
+		return ClientWebhook{}, ErrClientWebhookNotFound
+	}
+	return w, nil
+}
+
+func (m *memoryRepository) CreateDelivery(_ context.Context, d Delivery) error {
+	if _, ok := m.deliveries[d.ID]; ok {
+		return nil
+	}
+	m.deliveries[d.ID] = d
+	m.retry[d.ID] = d.NextAttemptAt
+	return nil
+}
+
+func (m *memoryRepository) SaveDelivery(_ context.Context, d Delivery) error {
+	m.deliveries[d.ID] = d
+	return nil
+}
+
+func (m *memoryRepository) FindDelivery(_ context.Context, id string) (Delivery, error) {
+	d, ok := m.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+func (m *memoryRepository) ListDeliveries(context.Context, string) ([]Delivery, error) {
+	return nil, nil
+}
+
+func (m *memoryRepository) ScheduleDelivery(_ context.Context, id string, at time.Time) error {
+	m.retry[id] = at
+	return nil
+}
+
+func (m *memoryRepository) ClaimDueDeliveries(_ context.Context, now time.Time, lease time.Duration, _ int64) ([]string, error) {
+	var ids []string
+	for id, at := range m.retry {
+		if !at.After(now) {
+			m.retry[id] = now.Add(lease)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *memoryRepository) CompleteDelivery(_ context.Context, id string) error {
+	delete(m.retry, id)
+	return nil
+}
+
+func TestDeliver_LeaseUntilFinished(t *testing.T) {
+	ctx := context.Background()
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	r := newMemoryRepository()
+	s := NewService(r)
+	// O client de produção recusa loopback, onde o httptest escuta.
+	s.client = srv.Client()
+	now := time.Now()
+	for _, d := range []Delivery{
+		{ID: "ok", URL: srv.URL, Status: DeliveryStatusPending},
+		{ID: "orphan", URL: srv.URL, ClientID: "gone", Status: DeliveryStatusPending},
+	} {
+		require.NoError(t, r.SaveDelivery(ctx, d))
+		require.NoError(t, r.ScheduleDelivery(ctx, d.ID, now))
+	}
+
+	ids, err := r.ClaimDueDeliveries(ctx, now, deliveryLease, 10)
+	require.NoError(t, err)
+	assert.Len(t, ids, 2)
+	assert.Equal(t, now.Add(deliveryLease), r.retry["ok"], "o claim não tira a entrega da fila")
+
+	// Falha temporária: volta para a fila com backoff.
+	require.NoError(t, s.Deliver(ctx, "ok"))
+	assert.Contains(t, r.retry, "ok")
+	assert.Equal(t, DeliveryStatusPending, r.deliveries["ok"].Status)
+
+	require.NoError(t, s.Deliver(ctx, "ok"))
+	assert.NotContains(t, r.retry, "ok")
+	assert.Equal(t, DeliveryStatusDelivered, r.deliveries["ok"].Status)
+
+	// Cliente sem webhook: falha definitiva, fora da fila.
+	require.NoError(t, s.Deliver(ctx, "orphan"))
+	assert.NotContains(t, r.retry, "orphan")
+	assert.Equal(t, DeliveryStatusFailed, r.deliveries["orphan"].Status)
+}
+
+func TestHandleEvent_SchedulesOnCreate(t *testing.T) {
+	ctx := context.Background()
+	r := newMemoryRepository()
+	s := NewService(r)
+	event := payment.StatusEvent{ID: "1-0", Payment: payment.Payment{
+		CorrelationID: "abc",
+		Status:        payment.PaymentStatusSuccess,
+		CallbackURL:   "https://example.com/hook",
+	}}
+
+	// Reler o evento do stream não duplica nem perde a entrega.
+	require.NoError(t, s.HandleEvent(ctx, event))
+	require.NoError(t, s.HandleEvent(ctx, event))
+	assert.Len(t, r.deliveries, 1)
+	assert.Contains(t, r.retry, "1-0-callback")
+}
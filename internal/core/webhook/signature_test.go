@@ -0,0 +1,21 @@
+package webhook_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"id":"1-0-callback","type":"payment.finished"}`)
+	now := time.Unix(1752148800, 0)
+	header := webhook.Sign("s3cr3t", now, body)
+
+	assert.NoError(t, webhook.Verify("s3cr3t", header, body, now.Add(time.Minute), 5*time.Minute))
+	assert.ErrorIs(t, webhook.Verify("other", header, body, now, 5*time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("s3cr3t", header, []byte(`{}`), now, 5*time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("s3cr3t", header, body, now.Add(time.Hour), 5*time.Minute), webhook.ErrSignatureExpired)
+	assert.ErrorIs(t, webhook.Verify("s3cr3t", "v1=abc", body, now, 5*time.Minute), webhook.ErrInvalidSignature)
+}
@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"
+	DeliveryStatusDelivered DeliveryStatus = "delivered"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+const EventPaymentFinished = "payment.finished"
+
+// Event é o corpo enviado no webhook.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Payment   payment.Payment `json:"payment"`
+}
+
+// Delivery é uma entrega de webhook para uma URL. O payload é gravado já
+// serializado para que uma reentrega envie exatamente os mesmos bytes.
+type Delivery struct {
+	ID             string         `json:"id"`
+	CorrelationID  string         `json:"correlationId"`
+	URL            string         `json:"url"`
+	ClientID       string         `json:"clientId,omitempty"`
+	TenantID       string         `json:"tenantId,omitempty"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	LastStatusCode int            `json:"lastStatusCode,omitempty"`
+	LastError      string         `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt,omitzero"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	Payload        string         `json:"payload"`
+}
+
+// ClientWebhook é o endpoint cadastrado por um cliente (header X-Client-Id)
+// para receber o resultado de todos os seus pagamentos.
+type ClientWebhook struct {
+	ClientID string `json:"clientId"`
+	URL      string `json:"url"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+type ClientWebhookParams struct {
+	URL string `json:"url"`
+}
@@ -0,0 +1,299 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/oprimogus/rinha-backend-2025/internal/infra/database"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
+	"github.com/redis/go-redis/v9"
+)
+
+var webhookLog = logger.Component("webhook")
+
+const (
+	consumerGroup = "webhooks"
+	readCount     = 100
+	readBlock     = 2 * time.Second
+	pollInterval  = 500 * time.Millisecond
+	claimBatch    = 50
+	// pendingRetry é o intervalo entre as releituras dos eventos pendentes
+	// (lidos e não confirmados) depois de uma falha.
+	pendingRetry = 5 * time.Second
+	// staleAfter é quanto um evento pode ficar pendente com outro consumidor
+	// (ex.: uma instância que morreu) antes de ser assumido por este.
+	staleAfter = time.Minute
+)
+
+// eventStream é o consumer group do stream de eventos de pagamento.
+type eventStream interface {
+	// Read lê os eventos novos (id ">") ou os pendentes deste consumidor
+	// depois de id.
+	Read(ctx context.Context, id string) ([]redis.XMessage, error)
+	// ClaimStale assume os eventos pendentes com outros consumidores há mais
+	// de minIdle.
+	ClaimStale(ctx context.Context, minIdle time.Duration) ([]redis.XMessage, error)
+	Ack(ctx context.Context, id string) error
+}
+
+// DeliveryWorker lê o stream de eventos de pagamento num consumer group, de
+// forma que cada evento é tratado por uma única instância, e envia as
+// entregas vencidas.
+type DeliveryWorker struct {
+	rdb        *database.Redis
+	service    *Service
+	consumer   string
+	stream     eventStream
+	handle     func(context.Context, payment.StatusEvent) error
+	retryDelay time.Duration
+	wg         sync.WaitGroup
+}
+
+func NewDeliveryWorker(db *database.Redis) *DeliveryWorker {
+	consumer, err := os.Hostname()
+	if err != nil || consumer == "" {
+		consumer = "api"
+	}
+	service := NewService(NewRepository(db))
+	return &DeliveryWorker{
+		rdb:        db,
+		service:    service,
+		consumer:   consumer,
+		stream:     &redisStream{rdb: db, consumer: consumer},
+		handle:     service.HandleEvent,
+		retryDelay: pendingRetry,
+	}
+}
+
+func (w *DeliveryWorker) Run(ctx context.Context) {
+	err := w.rdb.XGroupCreateMkStream(ctx, payment.EventsStream, consumerGroup, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		webhookLog.Error("fail on create webhook consumer group", "error", err)
+		return
+	}
+
+	w.wg.Add(2)
+	go w.consumeEvents(ctx)
+	go w.deliverDue(ctx)
+}
+
+// consumeEvents lê os eventos novos. Um evento que falha fica pendente, sem
+// XACK, e é relido com os demais pendentes a cada retryDelay até dar certo.
+// No start os pendentes são relidos na hora (ex.: reinício antes do XACK).
+func (w *DeliveryWorker) consumeEvents(ctx context.Context) {
+	defer w.wg.Done()
+	webhookLog.Info("Starting webhook event consumer", "consumer", w.consumer)
+
+	pending := true
+	var retryAt, claimAt time.Time
+	for {
+		if ctx.Err() != nil {
+			webhookLog.Info("Webhook event consumer stopped")
+			return
+		}
+
+		now := time.Now()
+		if !now.Before(claimAt) {
+			claimAt = now.Add(staleAfter)
+			if !w.claimStale(ctx) {
+				pending = true
+			}
+		}
+		if pending && !now.Before(retryAt) {
+			pending = !w.drainPending(ctx)
+			retryAt = now.Add(w.retryDelay)
+		}
+
+		messages, err := w.stream.Read(ctx, ">")
+		if err != nil {
+			if ctx.Err() == nil {
+				webhookLog.Error("fail on read payment events", "error", err)
+				sleep(ctx, pollInterval)
+			}
+			continue
+		}
+		for _, msg := range messages {
+			if !w.handleMessage(ctx, msg) && !pending {
+				pending = true
+				retryAt = time.Now().Add(w.retryDelay)
+			}
+		}
+	}
+}
+
+// drainPending relê todos os eventos pendentes deste consumidor. Devolve
+// false se algum falhou de novo.
+func (w *DeliveryWorker) drainPending(ctx context.Context) bool {
+	ok := true
+	id := "0"
+	for {
+		messages, err := w.stream.Read(ctx, id)
+		if err != nil {
+			if ctx.Err() == nil {
+				webhookLog.Error("fail on read pending payment events", "error", err)
+			}
+			return false
+		}
+		if len(messages) == 0 {
+			return ok
+		}
+		for _, msg := range messages {
+			if !w.handleMessage(ctx, msg) {
+				ok = false
+			}
+		}
+		id = messages[len(messages)-1].ID
+	}
+}
+
+// claimStale trata os eventos que outro consumidor deixou pendentes. Os que
+// falharem passam a ser pendentes deste consumidor.
+func (w *DeliveryWorker) claimStale(ctx context.Context) bool {
+	messages, err := w.stream.ClaimStale(ctx, staleAfter)
+	if err != nil {
+		if ctx.Err() == nil {
+			webhookLog.Error("fail on claim stale payment events", "error", err)
+		}
+		// Parte pode ter sido assumida antes do erro.
+		return false
+	}
+	ok := true
+	for _, msg := range messages {
+		if !w.handleMessage(ctx, msg) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// handleMessage devolve false se o evento deve ser tentado de novo.
+func (w *DeliveryWorker) handleMessage(ctx context.Context, msg redis.XMessage) bool {
+	event, err := payment.DecodeStatusEvent(msg)
+	if err != nil {
+		webhookLog.Warn("discarding invalid payment event", "id", msg.ID, "error", err)
+	} else if err := w.handle(ctx, event); err != nil {
+		// Sem XACK: o evento fica pendente e volta no próximo drainPending.
+		webhookLog.Error("fail on handle payment event", "id", msg.ID, "error", err)
+		return false
+	}
+
+	if err := w.stream.Ack(ctx, msg.ID); err != nil {
+		webhookLog.Error("fail on ack payment event", "id", msg.ID, "error", err)
+	}
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+type redisStream struct {
+	rdb      *database.Redis
+	consumer string
+}
+
+func (s *redisStream) Read(ctx context.Context, id string) ([]redis.XMessage, error) {
+	streams, err := s.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    consumerGroup,
+		Consumer: s.consumer,
+		Streams:  []string{payment.EventsStream, id},
+		Count:    readCount,
+		Block:    readBlock,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var messages []redis.XMessage
+	for _, st := range streams {
+		messages = append(messages, st.Messages...)
+	}
+	return messages, nil
+}
+
+func (s *redisStream) ClaimStale(ctx context.Context, minIdle time.Duration) ([]redis.XMessage, error) {
+	var claimed []redis.XMessage
+	start := "0-0"
+	for {
+		messages, next, err := s.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   payment.EventsStream,
+			Group:    consumerGroup,
+			Consumer: s.consumer,
+			MinIdle:  minIdle,
+			Start:    start,
+			Count:    readCount,
+		}).Result()
+		if err != nil {
+			return claimed, err
+		}
+		claimed = append(claimed, messages...)
+		if next == "0-0" || next == "" {
+			return claimed, nil
+		}
+		start = next
+	}
+}
+
+func (s *redisStream) Ack(ctx context.Context, id string) error {
+	return s.rdb.XAck(ctx, payment.EventsStream, consumerGroup, id).Err()
+}
+
+func (w *DeliveryWorker) deliverDue(ctx context.Context) {
+	defer w.wg.Done()
+	webhookLog.Info("Starting webhook delivery worker")
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			webhookLog.Info("Webhook delivery worker stopped")
+			return
+		case <-ticker.C:
+			ids, err := w.service.r.ClaimDueDeliveries(ctx, time.Now(), deliveryLease, claimBatch)
+			if err != nil {
+				webhookLog.Error("fail on claim webhook deliveries", "error", err)
+				continue
+			}
+			// Em paralelo: em sequência, claimBatch envios de até sendTimeout
+			// passariam do deliveryLease e outra instância reenviaria o resto.
+			var batch sync.WaitGroup
+			for _, id := range ids {
+				batch.Add(1)
+				go func() {
+					defer batch.Done()
+					if err := w.service.Deliver(ctx, id); err != nil {
+						webhookLog.Error("fail on deliver webhook", "delivery", id, "error", err)
+					}
+				}()
+			}
+			batch.Wait()
+		}
+	}
+}
+
+func (w *DeliveryWorker) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/core/payment"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStream imita um consumer group com um único consumidor: Read(">")
+// entrega cada evento novo uma vez e o guarda como pendente até o Ack.
+type memoryStream struct {
+	mu      sync.Mutex
+	fresh   []redis.XMessage
+	pending []redis.XMessage
+}
+
+func (m *memoryStream) Read(_ context.Context, id string) ([]redis.XMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id == ">" {
+		msgs := m.fresh
+		m.fresh = nil
+		m.pending = append(m.pending, msgs...)
+		if len(msgs) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		return msgs, nil
+	}
+	var msgs []redis.XMessage
+	for _, msg := range m.pending {
+		if id == "0" || msg.ID > id {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+func (m *memoryStream) ClaimStale(context.Context, time.Duration) ([]redis.XMessage, error) {
+	return nil, nil
+}
+
+func (m *memoryStream) Ack(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = slices.DeleteFunc(m.pending, func(msg redis.XMessage) bool { return msg.ID == id })
+	return nil
+}
+
+func (m *memoryStream) pendingCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.pending)
+}
+
+func TestConsumeEvents_RetriesFailedEvent(t *testing.T) {
+	stream := &memoryStream{fresh: []redis.XMessage{
+		{ID: "1-0", Values: map[string]any{"payment": `{"correlationId":"a","status":"success"}`}},
+		{ID: "2-0", Values: map[string]any{"payment": `{"correlationId":"b","status":"success"}`}},
+	}}
+
+	var mu sync.Mutex
+	var handled []string
+	failed := false
+	w := &DeliveryWorker{
+		stream:     stream,
+		retryDelay: 10 * time.Millisecond,
+		handle: func(_ context.Context, e payment.StatusEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if e.Payment.CorrelationID == "a" && !failed {
+				failed = true
+				return assert.AnError
+			}
+			handled = append(handled, e.Payment.CorrelationID)
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.wg.Add(1)
+	go w.consumeEvents(ctx)
+	defer func() {
+		cancel()
+		w.wg.Wait()
+	}()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"b", "a"}, handled)
+	assert.Zero(t, stream.pendingCount())
+}
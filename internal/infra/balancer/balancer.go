@@ -0,0 +1,223 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
+)
+
+var lbLog = logger.Component("lb")
+
+// upstream é uma instância da API. active conta as requisições em andamento
+// e é o critério do least-connections.
+type upstream struct {
+	name      string
+	transport *http.Transport
+	proxy     *httputil.ReverseProxy
+	active    atomic.Int64
+	healthy   atomic.Bool
+	// fails só é usado pelo health check, que roda numa goroutine só.
+	fails int
+}
+
+// Balancer distribui as requisições para o upstream saudável com menos
+// requisições em andamento. Os upstreams que falham no health check
+// (LB_HEALTH_PATH, por padrão o /readyz) saem do balanceamento até voltarem
+// a responder 2xx, a não ser que todos tenham saído.
+type Balancer struct {
+	upstreams []*upstream
+	cfg       config.Balancer
+	// next desempata o least-connections: com todos ociosos, o primeiro
+	// upstream receberia tudo.
+	next atomic.Uint64
+}
+
+func New(cfg config.Balancer) *Balancer {
+	b := &Balancer{cfg: cfg}
+	pool := &bufferPool{}
+	for _, u := range cfg.Upstreams {
+		b.upstreams = append(b.upstreams, newUpstream(u, cfg, pool))
+	}
+	return b
+}
+
+func newUpstream(u config.Upstream, cfg config.Balancer, pool httputil.BufferPool) *upstream {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		// O host da URL é ignorado: toda conexão vai para o endereço do upstream.
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, u.Network, u.Address)
+		},
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConns,
+		IdleConnTimeout:     cfg.IdleTimeout,
+		DisableCompression:  true,
+	}
+	up := &upstream{name: u.String(), transport: transport}
+	up.healthy.Store(true)
+	up.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = "http"
+			r.Out.URL.Host = "upstream"
+			r.Out.Host = r.In.Host
+			r.SetXForwarded()
+			if ip, _, err := net.SplitHostPort(r.In.RemoteAddr); err == nil {
+				r.Out.Header.Set("X-Real-IP", ip)
+			}
+		},
+		Transport: transport,
+		// Flush imediato para o SSE de /payments/{id}/events.
+		FlushInterval: -1,
+		BufferPool:    pool,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// Cliente que desistiu não é falha do upstream.
+			if !errors.Is(err, context.Canceled) {
+				lbLog.Warn("upstream request failed", "upstream", up.name, "error", err)
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return up
+}
+
+func (b *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u := b.pick()
+	if u == nil {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	u.active.Add(1)
+	defer u.active.Add(-1)
+	u.proxy.ServeHTTP(w, r)
+}
+
+// pick devolve o upstream saudável com menos requisições em andamento. Se
+// nenhum estiver saudável, escolhe entre todos: o /readyz cai junto nas
+// instâncias quando uma dependência compartilhada falha (Redis, fila,
+// processadores), e recusar tudo transformaria isso em queda total, até do
+// GET /payments-summary.
+func (b *Balancer) pick() *upstream {
+	n := len(b.upstreams)
+	if n == 0 {
+		return nil
+	}
+	start := int(b.next.Add(1) % uint64(n))
+	if u := b.leastActive(start, true); u != nil {
+		return u
+	}
+	return b.leastActive(start, false)
+}
+
+func (b *Balancer) leastActive(start int, healthyOnly bool) *upstream {
+	n := len(b.upstreams)
+	var best *upstream
+	var bestActive int64
+	for i := range n {
+		u := b.upstreams[(start+i)%n]
+		if healthyOnly && !u.healthy.Load() {
+			continue
+		}
+		if active := u.active.Load(); best == nil || active < bestActive {
+			best, bestActive = u, active
+		}
+	}
+	return best
+}
+
+// Run consulta o health check de cada upstream a cada HealthInterval até ctx
+// terminar. A primeira rodada é imediata.
+func (b *Balancer) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		b.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Balancer) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, u := range b.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.check(ctx, u)
+		}()
+	}
+	wg.Wait()
+}
+
+func (b *Balancer) check(ctx context.Context, u *upstream) {
+	ctx, cancel := context.WithTimeout(ctx, b.cfg.HealthTimeout)
+	defer cancel()
+
+	err := probe(ctx, u.transport, b.cfg.HealthPath)
+	if err == nil {
+		u.fails = 0
+		if !u.healthy.Swap(true) {
+			lbLog.Info("upstream is healthy again", "upstream", u.name)
+		}
+		return
+	}
+	u.fails++
+	if u.fails >= b.cfg.FailThreshold && u.healthy.Swap(false) {
+		lbLog.Warn("upstream ejected", "upstream", u.name, "fails", u.fails, "error", err)
+	}
+}
+
+// probe usa o transport do próprio upstream, reaproveitando as conexões.
+func probe(ctx context.Context, transport http.RoundTripper, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://upstream"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	// Ler o corpo até o fim devolve a conexão para o pool.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close fecha as conexões ociosas com os upstreams.
+func (b *Balancer) Close() {
+	for _, u := range b.upstreams {
+		u.transport.CloseIdleConnections()
+	}
+}
+
+// bufferPool reaproveita os buffers de cópia do corpo entre requisições.
+type bufferPool struct {
+	pool sync.Pool
+}
+
+func (p *bufferPool) Get() []byte {
+	if b, ok := p.pool.Get().(*[]byte); ok {
+		return *b
+	}
+	return make([]byte, 32*1024)
+}
+
+func (p *bufferPool) Put(b []byte) {
+	p.pool.Put(&b)
+}
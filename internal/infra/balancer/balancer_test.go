@@ -0,0 +1,157 @@
+package balancer
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend responde com o próprio nome; /readyz devolve 503 enquanto ready for
+// false e /slow segura a requisição até release fechar.
+type backend struct {
+	name    string
+	ready   atomic.Bool
+	release chan struct{}
+}
+
+func newBackend(name string) *backend {
+	b := &backend{name: name, release: make(chan struct{})}
+	b.ready.Store(true)
+	return b
+}
+
+func (b *backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/readyz":
+		if !b.ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	case "/slow":
+		<-b.release
+	}
+	io.WriteString(w, b.name)
+}
+
+func tcpUpstream(t *testing.T, h http.Handler) config.Upstream {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return config.Upstream{Network: "tcp", Address: strings.TrimPrefix(srv.URL, "http://")}
+}
+
+func newBalancer(upstreams ...config.Upstream) *Balancer {
+	return New(config.Balancer{
+		Upstreams:      upstreams,
+		HealthPath:     "/readyz",
+		HealthInterval: time.Hour,
+		HealthTimeout:  time.Second,
+		FailThreshold:  2,
+		MaxIdleConns:   8,
+		IdleTimeout:    time.Minute,
+	})
+}
+
+func get(t *testing.T, b *Balancer, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestLeastConnections(t *testing.T) {
+	api1, api2 := newBackend("api1"), newBackend("api2")
+	b := newBalancer(tcpUpstream(t, api1), tcpUpstream(t, api2))
+	defer b.Close()
+
+	// Cada upstream fica com uma requisição presa; a próxima vai para o que
+	// tiver menos em andamento.
+	done := make(chan string, 2)
+	for i := range 2 {
+		go func() {
+			_, body := get(t, b, "/slow")
+			done <- body
+		}()
+		require.Eventually(t, func() bool {
+			return b.upstreams[0].active.Load()+b.upstreams[1].active.Load() == int64(i+1)
+		}, time.Second, time.Millisecond)
+	}
+	require.Equal(t, int64(1), b.upstreams[0].active.Load())
+
+	close(api1.release)
+	assert.Equal(t, "api1", <-done)
+	for range 5 {
+		_, body := get(t, b, "/")
+		assert.Equal(t, "api1", body)
+	}
+	close(api2.release)
+	assert.Equal(t, "api2", <-done)
+}
+
+func TestHealthEjection(t *testing.T) {
+	api1, api2 := newBackend("api1"), newBackend("api2")
+	b := newBalancer(tcpUpstream(t, api1), tcpUpstream(t, api2))
+	defer b.Close()
+	ctx := context.Background()
+
+	api1.ready.Store(false)
+	b.checkAll(ctx)
+	assert.True(t, b.upstreams[0].healthy.Load(), "uma falha não basta para ejetar")
+	b.checkAll(ctx)
+	assert.False(t, b.upstreams[0].healthy.Load())
+	for range 4 {
+		_, body := get(t, b, "/")
+		assert.Equal(t, "api2", body)
+	}
+
+	// Todos fora: o lb continua distribuindo entre eles em vez de responder
+	// 503 para tudo.
+	api2.ready.Store(false)
+	b.checkAll(ctx)
+	b.checkAll(ctx)
+	seen := map[string]bool{}
+	for range 4 {
+		code, body := get(t, b, "/")
+		assert.Equal(t, http.StatusOK, code)
+		seen[body] = true
+	}
+	assert.Equal(t, map[string]bool{"api1": true, "api2": true}, seen)
+
+	api1.ready.Store(true)
+	b.checkAll(ctx)
+	_, body := get(t, b, "/")
+	assert.Equal(t, "api1", body)
+}
+
+func TestUnixUpstream(t *testing.T) {
+	dir, err := os.MkdirTemp("", "lb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "api.sock")
+
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: newBackend("api1")}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	b := newBalancer(config.Upstream{Network: "unix", Address: path})
+	defer b.Close()
+	code, body := get(t, b, "/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "api1", body)
+
+	require.NoError(t, probe(context.Background(), b.upstreams[0].transport, "/readyz"))
+}
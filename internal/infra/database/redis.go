@@ -13,12 +13,20 @@ type Redis struct {
 	*redis.Client
 }
 
-func NewRedis(host string, port int, password string) (*Redis, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", host, port),
-		Password: password,
+// NewRedis conecta por TCP em Host:Port ou, se cfg.Socket estiver definido,
+// pelo Unix socket.
+func NewRedis(cfg config.Redis) (*Redis, error) {
+	opts := &redis.Options{
+		Network:  "tcp",
+		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password: cfg.Password,
 		DB:       0,
-	})
+	}
+	if cfg.Socket != "" {
+		opts.Network = "unix"
+		opts.Addr = cfg.Socket
+	}
+	client := redis.NewClient(opts)
 
 	if err := client.Ping(context.Background()).Err(); err != nil {
 		return nil, err
@@ -30,7 +38,7 @@ func NewRedis(host string, port int, password string) (*Redis, error) {
 func GetRedis() *Redis {
     if rdb == nil {
         cfg := config.GetInstance()
-        instance, err := NewRedis(cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password)
+        instance, err := NewRedis(cfg.Redis)
         if err != nil {
             panic(err)
         }
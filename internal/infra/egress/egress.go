@@ -0,0 +1,103 @@
+// Package egress protege as chamadas para URLs informadas por clientes
+// (callbackUrl e webhooks) contra SSRF: elas não podem alcançar o Redis, os
+// processadores ou qualquer endereço interno.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidURL       = errors.New("url must be an absolute http(s) URL")
+	ErrForbiddenAddress = errors.New("url resolves to a private or local address")
+)
+
+const resolveTimeout = 2 * time.Second
+
+// cgnat é o 100.64.0.0/10, usado por provedores como rede interna.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// Allowed indica se o endereço é público: loopback, redes privadas,
+// link-local (inclui o 169.254.169.254 dos metadados de cloud), multicast e
+// não especificado são recusados.
+func Allowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!cgnat.Contains(ip)
+}
+
+// CheckURL confere que raw é uma URL http(s) absoluta cujo host resolve só
+// para endereços públicos. O Dialer confere de novo na conexão, porque o DNS
+// pode mudar entre a validação e o envio.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil {
+		if !Allowed(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	for _, ip := range addrs {
+		if !Allowed(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// Dialer recusa conectar em endereços que não são públicos. A checagem roda
+// depois da resolução do nome, no endereço que vai de fato ser usado.
+func Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil || !Allowed(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		},
+	}
+}
+
+// Client é um http.Client que só alcança endereços públicos. O proxy do
+// ambiente é ignorado: ele faria a conexão no lugar do Dialer.
+func Client(timeout time.Duration) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = Dialer().DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: t,
+	}
+}
@@ -0,0 +1,41 @@
+package egress
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowed(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.0.0.5", "172.18.0.3", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		assert.False(t, Allowed(netip.MustParseAddr(ip)), ip)
+	}
+	for _, ip := range []string{"8.8.8.8", "203.0.113.10", "2001:4860:4860::8888"} {
+		assert.True(t, Allowed(netip.MustParseAddr(ip)), ip)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, CheckURL(ctx, "https://203.0.113.10/hooks"))
+	assert.ErrorIs(t, CheckURL(ctx, "ftp://203.0.113.10/hooks"), ErrInvalidURL)
+	assert.ErrorIs(t, CheckURL(ctx, "/hooks"), ErrInvalidURL)
+	assert.ErrorIs(t, CheckURL(ctx, "http://169.254.169.254/latest/meta-data"), ErrForbiddenAddress)
+	assert.ErrorIs(t, CheckURL(ctx, "http://[::1]:6379"), ErrForbiddenAddress)
+	assert.ErrorIs(t, CheckURL(ctx, "http://localhost:8080/hooks"), ErrForbiddenAddress)
+}
+
+func TestClient_RefusesLocalAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := Client(time.Second).Get(srv.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+}
@@ -0,0 +1,66 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socketPath evita o t.TempDir(): o caminho de um Unix socket tem limite de
+// ~100 bytes e o nome do teste entra no diretório.
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "uds")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "api.sock")
+}
+
+func TestUnix(t *testing.T) {
+	path := socketPath(t)
+
+	l, err := Unix(path, 0o660)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+
+	_, err = Unix(path, 0o660)
+	assert.ErrorContains(t, err, "in use")
+
+	require.NoError(t, l.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Close remove o socket")
+}
+
+func TestUnix_RemovesStaleSocket(t *testing.T) {
+	path := socketPath(t)
+
+	// Simula um processo que morreu sem remover o socket.
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+
+	l, err = Unix(path, 0o600)
+	require.NoError(t, err)
+	defer l.Close()
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestUnix_KeepsRegularFile(t *testing.T) {
+	path := socketPath(t)
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+
+	_, err := Unix(path, 0o660)
+	assert.ErrorContains(t, err, "not a socket")
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+)
+
+// Um nível global e, opcionalmente, um por componente. Os handlers consultam
+// os dois a cada registro, então mudanças valem na hora.
+var (
+	level      = new(slog.LevelVar)
+	components atomic.Pointer[map[string]slog.Level]
+)
+
+// Configure aplica os níveis, a amostragem e o mascaramento da
+// configuração; é chamado no start e a cada hot reload.
+func Configure(c config.Log) error {
+	if err := SetLevels(c.Level, c.Components); err != nil {
+		return err
+	}
+	SetSampling(Sampling{First: c.SamplingFirst, Thereafter: c.SamplingThereafter, Tick: c.SamplingTick})
+	SetRedaction(c.Redact)
+	return nil
+}
+
+// SetLevels troca o nível global e os níveis por componente de uma vez. Um
+// componente fora de byComponent volta a seguir o nível global.
+func SetLevels(global string, byComponent map[string]string) error {
+	g, err := parseLevel(global)
+	if err != nil {
+		return err
+	}
+	m := make(map[string]slog.Level, len(byComponent))
+	for c, name := range byComponent {
+		if m[c], err = parseLevel(name); err != nil {
+			return fmt.Errorf("component %s: %w", c, err)
+		}
+	}
+	level.Set(g)
+	components.Store(&m)
+	return nil
+}
+
+// Levels devolve o nível global e os níveis por componente em vigor.
+func Levels() (string, map[string]string) {
+	byComponent := make(map[string]string)
+	if m := components.Load(); m != nil {
+		for c, l := range *m {
+			byComponent[c] = levelName(l)
+		}
+	}
+	return levelName(level.Level()), byComponent
+}
+
+func levelFor(component string) slog.Level {
+	if m := components.Load(); m != nil && component != "" {
+		if l, ok := (*m)[component]; ok {
+			return l
+		}
+	}
+	return level.Level()
+}
+
+func parseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(name))
+	return l, err
+}
+
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+// Component devolve um logger com nível próprio (LOG_COMPONENTS e
+// /admin/log-levels) e o atributo "component" em cada registro. Pode ficar
+// numa variável de pacote, criada antes do InitLogger: o handler padrão é
+// resolvido a cada registro.
+func Component(name string) *slog.Logger {
+	return slog.New(componentHandler{name: name})
+}
+
+type componentHandler struct {
+	name string
+}
+
+func (h componentHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= levelFor(h.name)
+}
+
+func (h componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ch, ok := slog.Default().Handler().(*ContextHandler); ok {
+		return ch.handle(ctx, r, h.name)
+	}
+	r.AddAttrs(slog.String("component", h.name))
+	return slog.Default().Handler().Handle(ctx, r)
+}
+
+func (h componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.bind().WithAttrs(attrs)
+}
+
+func (h componentHandler) WithGroup(name string) slog.Handler {
+	return h.bind().WithGroup(name)
+}
+
+func (h componentHandler) bind() slog.Handler {
+	if ch, ok := slog.Default().Handler().(*ContextHandler); ok {
+		return &ContextHandler{Handler: ch.Handler, component: h.name}
+	}
+	return slog.Default().Handler().WithAttrs([]slog.Attr{slog.String("component", h.name)})
+}
@@ -29,12 +29,37 @@ func GetRequestContext(ctx context.Context) *RequestData {
 	return nil
 }
 
-// Custom handler que injeta RequestData do contexto
+// Custom handler que injeta RequestData do contexto. Também aplica o nível do
+// componente, a amostragem e o mascaramento de LOG_REDACT.
 type ContextHandler struct {
 	slog.Handler
+	component string
+}
+
+func (h *ContextHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= levelFor(h.component)
 }
 
 func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handle(ctx, r, h.component)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(redactAttrs(attrs)), component: h.component}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name), component: h.component}
+}
+
+func (h *ContextHandler) handle(ctx context.Context, r slog.Record, component string) error {
+	if !sample(component, r) {
+		return nil
+	}
+	r = redactRecord(r)
+	if component != "" {
+		r.AddAttrs(slog.String("component", component))
+	}
 	if req := GetRequestContext(ctx); req != nil {
 		r.AddAttrs(slog.Group("request",
 			slog.String("trace_id", req.TraceID),
@@ -66,10 +91,24 @@ func (w *loggingResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+func (w *loggingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap permite que http.ResponseController alcance o writer original.
+func (w *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+var httpLog = Component("http")
 
+// InitLogger deixa o filtro de nível com o ContextHandler; o JSONHandler
+// aceita tudo que chegar até ele.
 func InitLogger(out io.Writer) {
 	opts := &slog.HandlerOptions{
-		Level:       slog.LevelInfo,
+		Level:       slog.Level(-1 << 10),
 		ReplaceAttr: httplog.SchemaECS.Concise(true).ReplaceAttr,
 	}
 	base := slog.NewJSONHandler(out, opts)
@@ -96,7 +135,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
-		slog.Info("request handled",
+		httpLog.Info("request handled",
 			slog.Group("request",
 				slog.String("trace_id", reqData.TraceID),
 				slog.String("method", reqData.Method),
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	prev := slog.Default()
+	var buf bytes.Buffer
+	InitLogger(&buf)
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		require.NoError(t, SetLevels("info", nil))
+		SetSampling(Sampling{})
+		SetRedaction(nil)
+	})
+	return &buf
+}
+
+func TestComponentLevels(t *testing.T) {
+	buf := setup(t)
+	worker := Component("worker")
+	require.NoError(t, SetLevels("warn", map[string]string{"worker": "debug"}))
+
+	slog.Info("dropped by global level")
+	worker.Debug("kept by component level")
+	Component("processor").Info("dropped by global level")
+
+	out := buf.String()
+	assert.NotContains(t, out, "dropped by global level")
+	assert.Contains(t, out, "kept by component level")
+	assert.Contains(t, out, `"component":"worker"`)
+
+	level, components := Levels()
+	assert.Equal(t, "warn", level)
+	assert.Equal(t, map[string]string{"worker": "debug"}, components)
+
+	assert.Error(t, SetLevels("info", map[string]string{"worker": "loud"}))
+	level, _ = Levels()
+	assert.Equal(t, "warn", level, "nível inválido não muda nada")
+}
+
+func TestSampling_NeverDropsErrors(t *testing.T) {
+	buf := setup(t)
+	SetSampling(Sampling{First: 2, Thereafter: 5, Tick: time.Hour})
+	before := Dropped()
+
+	log := Component("worker")
+	for range 12 {
+		log.Info("hot path")
+		log.Error("failure")
+	}
+
+	out := buf.String()
+	// 2 primeiros, depois o 7º e o 12º.
+	assert.Equal(t, 4, strings.Count(out, "hot path"))
+	assert.Equal(t, 12, strings.Count(out, "failure"))
+	assert.Equal(t, uint64(8), Dropped()-before)
+}
+
+type charge struct {
+	id     string
+	amount float64
+}
+
+func (c charge) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("correlationId", c.id), slog.Float64("amount", c.amount))
+}
+
+func TestRedaction(t *testing.T) {
+	buf := setup(t)
+	SetRedaction(map[string]string{"correlationId": "partial", "amount": "full", "authorization": "full"})
+
+	log := Component("worker").With("correlation_id", "4a7d1ed4-1b2c-4d5e-8f90-a1b2c3d49c1f")
+	log.Info("charged",
+		"payment", charge{id: "4a7d1ed4-1b2c-4d5e-8f90-a1b2c3d49c1f", amount: 19.9},
+		"headers", http.Header{"Authorization": {"Bearer rk_secret"}, "Accept": {"application/json"}},
+		"short", slog.GroupValue(slog.String("CorrelationID", "abc")),
+	)
+
+	out := buf.String()
+	assert.NotContains(t, out, "19.9")
+	assert.NotContains(t, out, "rk_secret")
+	assert.NotContains(t, out, "1b2c-4d5e")
+	assert.Equal(t, 2, strings.Count(out, `"4a7d****9c1f"`), "atributos do With e do LogValuer")
+	assert.Contains(t, out, `"amount":"[REDACTED]"`)
+	assert.Contains(t, out, `"Accept":"application/json"`)
+	assert.Contains(t, out, `"CorrelationID":"[REDACTED]"`)
+}
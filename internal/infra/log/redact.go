@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/oprimogus/rinha-backend-2025/internal/config"
+)
+
+const redacted = "[REDACTED]"
+
+// redaction guarda as regras de LOG_REDACT com as chaves normalizadas por
+// config.RedactKey. Sem regras nenhum atributo é mascarado.
+var redaction atomic.Pointer[map[string]string]
+
+// SetRedaction troca as regras de mascaramento: chave do atributo -> "full"
+// ou "partial".
+func SetRedaction(rules map[string]string) {
+	m := make(map[string]string, len(rules))
+	for k, mode := range rules {
+		m[config.RedactKey(k)] = mode
+	}
+	redaction.Store(&m)
+}
+
+// redactRecord devolve r com os atributos mascarados. Valores que implementam
+// slog.LogValuer são resolvidos antes, para que as chaves dos grupos que eles
+// devolvem também passem pelas regras.
+func redactRecord(r slog.Record) slog.Record {
+	rules := redaction.Load()
+	if rules == nil || len(*rules) == 0 || r.NumAttrs() == 0 {
+		return r
+	}
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(*rules, a))
+		return true
+	})
+	return out
+}
+
+func redactAttrs(attrs []slog.Attr) []slog.Attr {
+	rules := redaction.Load()
+	if rules == nil || len(*rules) == 0 {
+		return attrs
+	}
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = redactAttr(*rules, a)
+	}
+	return out
+}
+
+func redactAttr(rules map[string]string, a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if mode, ok := rules[config.RedactKey(a.Key)]; ok {
+		return slog.String(a.Key, mask(mode, a.Value))
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		out := make([]slog.Attr, len(group))
+		for i, child := range group {
+			out[i] = redactAttr(rules, child)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+	case slog.KindAny:
+		// Headers viram um grupo para que Authorization, X-Api-Key etc. sejam
+		// mascarados pelo nome.
+		if h, ok := a.Value.Any().(http.Header); ok {
+			out := make([]slog.Attr, 0, len(h))
+			for name, values := range h {
+				out = append(out, redactAttr(rules, slog.String(name, strings.Join(values, ", "))))
+			}
+			return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+		}
+	}
+	return a
+}
+
+// mask no modo partial mantém os 4 primeiros e os 4 últimos caracteres, o
+// bastante para correlacionar logs sem expor o valor inteiro.
+func mask(mode string, v slog.Value) string {
+	if mode != "partial" {
+		return redacted
+	}
+	s := v.String()
+	if len(s) <= 8 {
+		return redacted
+	}
+	return s[:4] + "****" + s[len(s)-4:]
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxSampleKeys limita a memória quando as mensagens não são constantes.
+const maxSampleKeys = 10000
+
+var (
+	sampling atomic.Pointer[Sampling]
+	dropped  atomic.Uint64
+
+	samplerMu sync.Mutex
+	counters  = make(map[sampleKey]*sampleCounter)
+)
+
+type sampleKey struct {
+	component string
+	message   string
+	level     slog.Level
+}
+
+type sampleCounter struct {
+	start time.Time
+	n     int
+}
+
+// Sampling limita, por componente e mensagem, quantos registros abaixo de
+// error saem a cada Tick: os First primeiros e, depois, um a cada
+// Thereafter (zero descarta todos). Errors nunca são descartados.
+type Sampling struct {
+	First      int
+	Thereafter int
+	Tick       time.Duration
+}
+
+// SetSampling troca a amostragem; First zero desliga.
+func SetSampling(s Sampling) {
+	if s.First <= 0 || s.Tick <= 0 {
+		sampling.Store(nil)
+		return
+	}
+	sampling.Store(&s)
+}
+
+// Dropped conta os registros descartados pela amostragem desde o start.
+func Dropped() uint64 {
+	return dropped.Load()
+}
+
+func sample(component string, r slog.Record) bool {
+	if r.Level >= slog.LevelError {
+		return true
+	}
+	s := sampling.Load()
+	if s == nil {
+		return true
+	}
+
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := sampleKey{component: component, message: r.Message, level: r.Level}
+
+	samplerMu.Lock()
+	c, ok := counters[key]
+	if !ok {
+		if len(counters) >= maxSampleKeys {
+			clear(counters)
+		}
+		c = &sampleCounter{start: now}
+		counters[key] = c
+	}
+	if now.Sub(c.start) >= s.Tick {
+		c.start, c.n = now, 0
+	}
+	c.n++
+	n := c.n
+	samplerMu.Unlock()
+
+	if n <= s.First || (s.Thereafter > 0 && (n-s.First)%s.Thereafter == 0) {
+		return true
+	}
+	dropped.Add(1)
+	return false
+}
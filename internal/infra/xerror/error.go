@@ -3,7 +3,12 @@ package xerror
 type CustomError struct {
     Code    int `json:"-"`
     Message string `json:"message"`
-    Err error `json:"error"`
+    // Err é a causa, só para logs: detalhes internos não vão na resposta.
+    Err error `json:"-"`
+    // ErrorCode é o código estável do problema; vazio, vem do mapeamento de
+    // Err ou do status.
+    ErrorCode string `json:"-"`
+    Fields []FieldError `json:"-"`
 }
 
 func NewCustomError(code int, message string, err error) *CustomError {
@@ -14,6 +19,21 @@ func NewCustomError(code int, message string, err error) *CustomError {
     }
 }
 
+// NewValidationError é um 400 que aponta o campo (ou parâmetro) inválido.
+func NewValidationError(field, message string, err error) *CustomError {
+    return &CustomError{
+        Code: 400,
+        Message: message,
+        Err: err,
+        ErrorCode: "validation_error",
+        Fields: []FieldError{{Field: field, Message: message}},
+    }
+}
+
 func (e *CustomError) Error() string {
     return e.Message
-}
\ No newline at end of file
+}
+
+func (e *CustomError) Unwrap() error {
+    return e.Err
+}
@@ -0,0 +1,138 @@
+package xerror
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
+)
+
+const ContentType = "application/problem+json"
+
+// TypePrefix forma o "type" do problema: TypePrefix + código estável.
+const TypePrefix = "urn:rinha:problem:"
+
+// Problem é o corpo de erro da API (RFC 7807).
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	TraceID  string       `json:"traceId,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Mapping diz como um erro de domínio vira resposta. Field, se definido, entra
+// em "errors" para que o cliente saiba qual campo corrigir.
+type Mapping struct {
+	Status int
+	Code   string
+	Field  string
+}
+
+var (
+	mu       sync.RWMutex
+	mappings []registered
+)
+
+type registered struct {
+	err error
+	m   Mapping
+}
+
+// Register associa um erro de domínio a um status e código. Os pacotes
+// registram os próprios erros no init; a busca usa errors.Is, então erros
+// embrulhados com %w também são reconhecidos.
+func Register(err error, m Mapping) {
+	mu.Lock()
+	defer mu.Unlock()
+	mappings = append(mappings, registered{err: err, m: m})
+}
+
+// lookup devolve o erro registrado que err embrulha. A mensagem usada na
+// resposta é a dele, não a de err, que pode carregar detalhes internos.
+func lookup(err error) (registered, bool) {
+	if err == nil {
+		return registered{}, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, reg := range mappings {
+		if errors.Is(err, reg.err) {
+			return reg, true
+		}
+	}
+	return registered{}, false
+}
+
+// ProblemFor traduz err para um Problem. Um *CustomError define status e
+// mensagem; um erro registrado usa o mapeamento; o resto é 500 sem detalhes.
+func ProblemFor(r *http.Request, err error) Problem {
+	p := Problem{Status: http.StatusInternalServerError, Detail: "internal server error"}
+
+	var cause error = err
+	var ce *CustomError
+	if errors.As(err, &ce) {
+		p.Status, p.Detail, p.Code, p.Errors = ce.Code, ce.Message, ce.ErrorCode, ce.Fields
+		cause = ce.Err
+	}
+	if reg, ok := lookup(cause); ok {
+		if ce == nil {
+			p.Status, p.Detail = reg.m.Status, reg.err.Error()
+		}
+		if p.Code == "" {
+			p.Code = reg.m.Code
+		}
+		if p.Errors == nil && reg.m.Field != "" {
+			p.Errors = []FieldError{{Field: reg.m.Field, Message: reg.err.Error()}}
+		}
+	}
+	if p.Code == "" {
+		p.Code = codeFor(p.Status)
+	}
+
+	p.Type = TypePrefix + p.Code
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+	if req := logger.GetRequestContext(r.Context()); req != nil {
+		p.TraceID = req.TraceID
+	}
+	return p
+}
+
+// Write responde err como application/problem+json. Erros 5xx são logados
+// com a causa, que não vai para o cliente.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFor(r, err)
+	if p.Status >= http.StatusInternalServerError {
+		cause := err
+		var ce *CustomError
+		if errors.As(err, &ce) && ce.Err != nil {
+			cause = ce.Err
+		}
+		slog.ErrorContext(r.Context(), "Request failed", "status", p.Status, "code", p.Code, "error", cause)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// codeFor deriva o código do status: 404 vira "not_found".
+func codeFor(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(text, "-", " ")), " ", "_")
+}
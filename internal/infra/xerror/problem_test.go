@@ -0,0 +1,77 @@
+package xerror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logger "github.com/oprimogus/rinha-backend-2025/internal/infra/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var errWidgetNotFound = errors.New("widget not found")
+
+var errInvalidSize = errors.New("size must be positive")
+
+func init() {
+	Register(errWidgetNotFound, Mapping{Status: http.StatusNotFound, Code: "widget_not_found"})
+	Register(errInvalidSize, Mapping{Status: http.StatusBadRequest, Code: "invalid_size", Field: "size"})
+}
+
+func write(err error) (*httptest.ResponseRecorder, Problem) {
+	req := httptest.NewRequest(http.MethodGet, "/widgets/1", nil)
+	req = req.WithContext(context.WithValue(req.Context(), logger.RequestKey, &logger.RequestData{TraceID: "trace-1"}))
+	rec := httptest.NewRecorder()
+	Write(rec, req, err)
+
+	var p Problem
+	json.NewDecoder(rec.Body).Decode(&p)
+	return rec, p
+}
+
+func TestWrite_RegisteredError(t *testing.T) {
+	rec, p := write(fmt.Errorf("%w: redis said no", errWidgetNotFound))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, Problem{
+		Type:     TypePrefix + "widget_not_found",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "widget not found",
+		Instance: "/widgets/1",
+		Code:     "widget_not_found",
+		TraceID:  "trace-1",
+	}, p)
+
+	_, p = write(errInvalidSize)
+	assert.Equal(t, []FieldError{{Field: "size", Message: "size must be positive"}}, p.Errors)
+}
+
+func TestWrite_CustomError(t *testing.T) {
+	rec, p := write(NewCustomError(http.StatusBadRequest, "invalid widget data", errors.New("unexpected EOF")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "bad_request", p.Code)
+	assert.Equal(t, "invalid widget data", p.Detail)
+	assert.NotContains(t, rec.Body.String(), "EOF")
+
+	_, p = write(NewValidationError("limit", "invalid 'limit'", nil))
+	assert.Equal(t, "validation_error", p.Code)
+	assert.Equal(t, []FieldError{{Field: "limit", Message: "invalid 'limit'"}}, p.Errors)
+
+	// Status do CustomError prevalece, o código vem do erro registrado
+	_, p = write(NewCustomError(http.StatusConflict, "widget busy", errWidgetNotFound))
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, "widget_not_found", p.Code)
+}
+
+func TestWrite_UnknownError(t *testing.T) {
+	rec, p := write(errors.New("dial tcp 10.0.0.1:6379: connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "internal_server_error", p.Code)
+	assert.Equal(t, "internal server error", p.Detail)
+	assert.NotContains(t, rec.Body.String(), "6379")
+}